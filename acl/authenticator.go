@@ -56,7 +56,11 @@ type Authenticator interface {
 	// utilities
 	CheckPassword(string, string) bool
 	CheckIP(string, net.Addr, net.Addr) bool
+	CheckIdent(string, string, net.Addr) bool
 	ChangePassword(string, string) error
+
+	// closes any underlying resources
+	Stop() error
 }
 
 // Entry describes an Authenticator Entry
@@ -97,6 +101,11 @@ func NewBadgerAuthenticator(db *badger.DB) *BadgerAuthenticator {
 	}
 }
 
+// Stop closes the underlying badger store
+func (a *BadgerAuthenticator) Stop() error {
+	return a.db.Close()
+}
+
 func (a *BadgerAuthenticator) encodeAndUpdate(tx *badger.Txn, e Entry) error {
 	e.SetUpdatedAt()
 
@@ -328,8 +337,6 @@ func (a *BadgerAuthenticator) updateEntry(e Entry, fn func(Entry) error) error {
 		}
 
 	}
-
-	return nil
 }
 
 // UpdateUser overwrites the User in the store
@@ -453,30 +460,8 @@ func (a *BadgerAuthenticator) CheckIP(name string, laddr, raddr net.Addr) bool {
 	}
 
 	// check all masks with a '*' to save us doing an ident lookup
-	for idx := range u.IPMasks {
-		parts := strings.Split(u.IPMasks[idx], "@")
-		if len(parts) != 2 {
-			continue
-		}
-
-		if parts[0] != "*" {
-			continue
-		}
-
-		// bit inefficient, but im sure we will survive. can optimise later TM
-		m, err := glob.Compile(parts[1], '.')
-		if err != nil {
-			fmt.Fprintf(
-				os.Stderr,
-				"ERROR compiling mask %d for user %s\n",
-				idx, u.Name,
-			)
-			continue
-		}
-
-		if m.Match(host) {
-			return true
-		}
+	if matchIPMasks(u, "", host) {
+		return true
 	}
 
 	ident, err := ident.Query(host, lport, rport, 10)
@@ -489,16 +474,52 @@ func (a *BadgerAuthenticator) CheckIP(name string, laddr, raddr net.Addr) bool {
 		return false
 	}
 
-	identifier := strings.ToLower(ident.Identifier)
+	return matchIPMasks(u, ident.Identifier, host)
+}
+
+// CheckIdent checks that the user is authorised on the ip with an ident that has already
+// been looked up, such as one sent by a bouncer with IDNT, so no ident query is made
+func (a *BadgerAuthenticator) CheckIdent(name, ident string, raddr net.Addr) bool {
+	u, err := a.GetUser(name)
+	if err != nil {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(raddr.String())
+	if err != nil {
+		return false
+	}
+
+	if matchIPMasks(u, "", host) {
+		return true
+	}
+
+	if len(ident) == 0 {
+		return false
+	}
+
+	return matchIPMasks(u, ident, host)
+}
+
+// matchIPMasks checks the user's masks against the ident and host. With an empty ident
+// only masks with a '*' ident are checked, otherwise only those matching the ident are
+func matchIPMasks(u *User, ident, host string) bool {
+	ident = strings.ToLower(ident)
 
 	for idx := range u.IPMasks {
 		parts := strings.Split(u.IPMasks[idx], "@")
 		if len(parts) != 2 {
 			continue
 		}
-		if strings.ToLower(parts[0]) != identifier || parts[0] == "*" {
+
+		if len(ident) == 0 {
+			if parts[0] != "*" {
+				continue
+			}
+		} else if strings.ToLower(parts[0]) != ident || parts[0] == "*" {
 			continue
 		}
+
 		// bit inefficient, but im sure we will survive. can optimise later TM
 		m, err := glob.Compile(parts[1], '.')
 		if err != nil {
@@ -1,6 +1,7 @@
 package acl
 
 import (
+	"net"
 	"testing"
 
 	"github.com/dgraph-io/badger/v2"
@@ -266,3 +267,54 @@ func TestAuthCheckPassword(t *testing.T) {
 		t.Fatal("expected false, got true")
 	}
 }
+
+func TestAuthCheckIdent(t *testing.T) {
+	auth := newAuthenticator(t)
+
+	addr := func(s string) net.Addr {
+		a, err := net.ResolveTCPAddr("tcp", s)
+		if err != nil {
+			t.Fatalf("expected nil, got %#v", err)
+		}
+		return a
+	}
+
+	// no user
+	if auth.CheckIdent("alice", "alice", addr("127.0.0.1:1234")) {
+		t.Fatal("expected false, got true")
+	}
+
+	if _, err := auth.AddUser("alice", "supersecret"); err != nil {
+		t.Fatalf("expected nil, got %#v", err)
+	}
+
+	err := auth.UpdateUser("alice", func(u *User) error {
+		if err := u.AddIP("alice@10.0.0.*"); err != nil {
+			return err
+		}
+		return u.AddIP("*@192.168.0.1")
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %#v", err)
+	}
+
+	var tests = []struct {
+		ident    string
+		addr     string
+		expected bool
+	}{
+		{"alice", "10.0.0.5:1234", true},
+		{"ALICE", "10.0.0.5:1234", true},
+		{"bob", "10.0.0.5:1234", false},
+		{"", "10.0.0.5:1234", false},
+		{"alice", "10.0.1.5:1234", false},
+		{"bob", "192.168.0.1:1234", true},
+		{"", "192.168.0.1:1234", true},
+	}
+
+	for _, tt := range tests {
+		if got := auth.CheckIdent("alice", tt.ident, addr(tt.addr)); got != tt.expected {
+			t.Errorf("%s@%s: expected %t, got %t", tt.ident, tt.addr, tt.expected, got)
+		}
+	}
+}
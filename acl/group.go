@@ -13,6 +13,20 @@ type Group struct {
 	Slots      int
 	LeechSlots int
 
+	// concurrent logins by users with this as their primary group, 0 uses
+	// the server default
+	Logins int
+
+	// simultaneous uploads and downloads for users with this as their
+	// primary group that don't have their own, 0 is unlimited
+	Uploads   int
+	Downloads int
+
+	// speed limits in KB/s shared by all users with this as their primary
+	// group, 0 is unlimited
+	DownloadSpeed int
+	UploadSpeed   int
+
 	Users map[string]*UserGroupMeta
 
 	CreatedAt time.Time
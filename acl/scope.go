@@ -5,31 +5,39 @@ package acl
 type PermissionScope string
 
 const (
-	PermissionScopeDownload  PermissionScope = "download"
-	PermissionScopeUpload                    = "upload"
-	PermissionScopeRename                    = "rename"
-	PermissionScopeRenameOwn                 = "renameown"
-	PermissionScopeDelete                    = "delete"
-	PermissionScopeDeleteOwn                 = "deleteown"
-	PermissionScopeResume                    = "resume"
-	PermissionScopeResumeOwn                 = "resumeown"
-	PermissionScopeMakeDir                   = "makedir"
-	PermissionScopeShowUser                  = "showuser"
-	PermissionScopeShowGroup                 = "showgroup"
-	PermissionScopePrivate                   = "private"
+	PermissionScopeDownload    PermissionScope = "download"
+	PermissionScopeUpload                      = "upload"
+	PermissionScopeRename                      = "rename"
+	PermissionScopeRenameOwn                   = "renameown"
+	PermissionScopeDelete                      = "delete"
+	PermissionScopeDeleteOwn                   = "deleteown"
+	PermissionScopeResume                      = "resume"
+	PermissionScopeResumeOwn                   = "resumeown"
+	PermissionScopeMakeDir                     = "makedir"
+	PermissionScopeShowUser                    = "showuser"
+	PermissionScopeShowGroup                   = "showgroup"
+	PermissionScopePrivate                     = "private"
+	PermissionScopeModTime                     = "modtime"
+	PermissionScopeModTimeOwn                  = "modtimeown"
+	PermissionScopeFXPUpload                   = "fxpupload"
+	PermissionScopeFXPDownload                 = "fxpdownload"
 )
 
 var StringToPermissionScope = map[string]PermissionScope{
-	string(PermissionScopeDownload):  PermissionScopeDownload,
-	string(PermissionScopeUpload):    PermissionScopeUpload,
-	string(PermissionScopeRename):    PermissionScopeRename,
-	string(PermissionScopeRenameOwn): PermissionScopeRenameOwn,
-	string(PermissionScopeDelete):    PermissionScopeDelete,
-	string(PermissionScopeDeleteOwn): PermissionScopeDeleteOwn,
-	string(PermissionScopeResume):    PermissionScopeResume,
-	string(PermissionScopeResumeOwn): PermissionScopeResumeOwn,
-	string(PermissionScopeMakeDir):   PermissionScopeMakeDir,
-	string(PermissionScopeShowUser):  PermissionScopeShowUser,
-	string(PermissionScopeShowGroup): PermissionScopeShowGroup,
-	string(PermissionScopePrivate):   PermissionScopePrivate,
+	string(PermissionScopeDownload):    PermissionScopeDownload,
+	string(PermissionScopeUpload):      PermissionScopeUpload,
+	string(PermissionScopeRename):      PermissionScopeRename,
+	string(PermissionScopeRenameOwn):   PermissionScopeRenameOwn,
+	string(PermissionScopeDelete):      PermissionScopeDelete,
+	string(PermissionScopeDeleteOwn):   PermissionScopeDeleteOwn,
+	string(PermissionScopeResume):      PermissionScopeResume,
+	string(PermissionScopeResumeOwn):   PermissionScopeResumeOwn,
+	string(PermissionScopeMakeDir):     PermissionScopeMakeDir,
+	string(PermissionScopeShowUser):    PermissionScopeShowUser,
+	string(PermissionScopeShowGroup):   PermissionScopeShowGroup,
+	string(PermissionScopePrivate):     PermissionScopePrivate,
+	string(PermissionScopeModTime):     PermissionScopeModTime,
+	string(PermissionScopeModTimeOwn):  PermissionScopeModTimeOwn,
+	string(PermissionScopeFXPUpload):   PermissionScopeFXPUpload,
+	string(PermissionScopeFXPDownload): PermissionScopeFXPDownload,
 }
@@ -28,11 +28,20 @@ type User struct {
 	Ratio   int
 	Credits int64
 
-	// login based attributes
+	// login based attributes, Uploads and Downloads are how many can run at
+	// once across all sessions, 0 uses the primary group's
 	Logins    int
 	Uploads   int
 	Downloads int
 
+	// speed limits in KB/s across all sessions, 0 uses the server default
+	DownloadSpeed int
+	UploadSpeed   int
+
+	// seconds without a command before being disconnected, 0 uses the
+	// server default
+	IdleTimeout int
+
 	// meta
 	AddedBy     string
 	CreatedAt   time.Time
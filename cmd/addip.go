@@ -25,6 +25,7 @@ func init() {
 			if err != nil {
 				return err
 			}
+			defer auth.Stop()
 
 			err = auth.UpdateUser(username, func(user *acl.User) error {
 				return user.AddIP(mask)
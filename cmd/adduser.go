@@ -24,6 +24,7 @@ func init() {
 			if err != nil {
 				return err
 			}
+			defer auth.Stop()
 
 			// add user
 			user, err := auth.AddUser(username, password)
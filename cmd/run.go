@@ -3,12 +3,54 @@ package cmd
 import (
 	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/goftpd/goftpd/config"
 	"github.com/goftpd/goftpd/ftp"
+	"github.com/goftpd/goftpd/vfs"
 	"github.com/spf13/cobra"
 )
 
+// reload parses the config again and swaps in the parts that can change
+// while running. Nothing is changed unless every namespace parses
+func reload(configPath string, server *ftp.Server, fs vfs.VFS) (*ftp.ServerOpts, error) {
+	cfg, err := config.ParseFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	serverOpts, err := cfg.ParseServerOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	fsOpts, err := cfg.ParseFSOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	perms, err := cfg.ParsePermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	se, err := cfg.ParseScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.Reload(serverOpts, se); err != nil {
+		return nil, err
+	}
+
+	fs.Reload(fsOpts, perms)
+
+	return serverOpts, nil
+}
+
 func init() {
 	var configPath string
 
@@ -38,6 +80,7 @@ func init() {
 			if err != nil {
 				return err
 			}
+			defer auth.Stop()
 
 			// get script engine
 			se, err := cfg.ParseScripts()
@@ -50,14 +93,80 @@ func init() {
 				return err
 			}
 
-			ctx := context.Background()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			signals := make(chan os.Signal, 2)
+			signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+			defer signal.Stop(signals)
+
+			reloads := make(chan os.Signal, 1)
+			signal.Notify(reloads, syscall.SIGHUP)
+			defer signal.Stop(reloads)
+
+			reopens := make(chan os.Signal, 1)
+			signal.Notify(reopens, syscall.SIGUSR1)
+			defer signal.Stop(reopens)
 
 			log.Printf("listen and serve..")
 
-			if err := server.ListenAndServe(ctx); err != nil {
+			errc := make(chan error, 1)
+			go func() {
+				errc <- server.ListenAndServe(ctx)
+			}()
+
+		wait:
+			for {
+				select {
+				case err := <-errc:
+					return err
+
+				case <-reloads:
+					opts, err := reload(configPath, server, fs)
+					if err != nil {
+						log.Printf("reload failed, keeping the current config: %s", err)
+						continue
+					}
+
+					serverOpts = opts
+					log.Printf("reloaded %s", configPath)
+
+				case <-reopens:
+					if err := server.ReopenTransferLog(); err != nil {
+						log.Printf("reopen xferlog: %s", err)
+						continue
+					}
+
+					log.Printf("reopened xferlog")
+
+				case sig := <-signals:
+					log.Printf("received %s, shutting down..", sig)
+					break wait
+				}
+			}
+
+			// a second signal doesn't wait for anything
+			go func() {
+				sig := <-signals
+				log.Printf("received %s, exiting now", sig)
+				os.Exit(1)
+			}()
+
+			drainCtx, drainCancel := context.WithTimeout(ctx, time.Duration(serverOpts.ShutdownTimeout)*time.Second)
+			defer drainCancel()
+
+			if err := server.Shutdown(drainCtx); err != nil {
+				log.Printf("shutdown: %s, transfers were aborted", err)
+			}
+
+			cancel()
+
+			if err := <-errc; err != nil {
 				return err
 			}
 
+			log.Printf("stopped")
+
 			return nil
 		},
 	}
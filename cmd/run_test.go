@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/config"
+	"github.com/goftpd/goftpd/ftp"
+)
+
+const testConfig = `
+server public_ip 127.0.0.1
+server tls_cert_file %[1]s/cert.pem
+server tls_key_file %[1]s/key.pem
+server host 127.0.0.1
+server port 2121
+server passive_ports 10000 10100
+fs rootpath %[1]s
+fs shadow_db %[1]s/shadow.db
+acl download /** %[2]s
+`
+
+func writeTestConfig(t *testing.T, path, dir, download string) {
+	t.Helper()
+
+	contents := fmt.Sprintf(testConfig, dir, download)
+
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("unexpected error writing config: %s", err)
+	}
+}
+
+func TestReloadBadACL(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "goftpd.conf")
+
+	writeTestConfig(t, configPath, dir, "*")
+
+	rootCmd.SetArgs([]string{
+		"gencert",
+		"--host", "127.0.0.1",
+		"--tlsCert", filepath.Join(dir, "cert.pem"),
+		"--tlsKey", filepath.Join(dir, "key.pem"),
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error creating certificate: %s", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), []byte("HELLO"), 0644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	cfg, err := config.ParseFile(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error parsing config: %s", err)
+	}
+
+	serverOpts, err := cfg.ParseServerOpts()
+	if err != nil {
+		t.Fatalf("unexpected error parsing server opts: %s", err)
+	}
+
+	fs, err := cfg.ParseFS()
+	if err != nil {
+		t.Fatalf("unexpected error parsing fs: %s", err)
+	}
+	defer fs.Stop()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("unexpected error opening db: %s", err)
+	}
+
+	auth := acl.NewBadgerAuthenticator(db)
+	defer auth.Stop()
+
+	se, err := cfg.ParseScripts()
+	if err != nil {
+		t.Fatalf("unexpected error parsing scripts: %s", err)
+	}
+
+	server, err := ftp.NewServer(serverOpts, fs, auth, se)
+	if err != nil {
+		t.Fatalf("unexpected error creating server: %s", err)
+	}
+
+	user := &acl.User{Name: "user", PrimaryGroup: "group"}
+
+	if err := fs.CheckDownload("/file", user); err != nil {
+		t.Fatalf("expected download to be allowed got: %s", err)
+	}
+
+	// a rule that doesn't parse rejects the whole reload
+	writeTestConfig(t, configPath, dir, "!*\nacl download")
+
+	if _, err := reload(configPath, server, fs); err == nil || !strings.Contains(err.Error(), "acl rule") {
+		t.Fatalf("expected an error parsing the acl rule got: %v", err)
+	}
+
+	if err := fs.CheckDownload("/file", user); err != nil {
+		t.Errorf("expected the running permissions to be kept got: %s", err)
+	}
+
+	// and a good one replaces them
+	writeTestConfig(t, configPath, dir, "!*")
+
+	if _, err := reload(configPath, server, fs); err != nil {
+		t.Fatalf("unexpected error reloading: %s", err)
+	}
+
+	if err := fs.CheckDownload("/file", user); err != acl.ErrPermissionDenied {
+		t.Errorf("expected %s got: %v", acl.ErrPermissionDenied, err)
+	}
+}
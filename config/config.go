@@ -216,6 +216,18 @@ func (c *Config) parse(lines []Line, opts interface{}) error {
 
 							reflect.Indirect(rv).Field(i).SetInt(int64(num))
 
+						case reflect.Bool:
+							if len(fields) > 2 {
+								return errors.Errorf("error parsing bool on line %d: too many fields", l.line)
+							}
+
+							b, err := strconv.ParseBool(fields[1])
+							if err != nil {
+								return errors.Errorf("error parsing bool on line %d: expected true or false", l.line)
+							}
+
+							reflect.Indirect(rv).Field(i).SetBool(b)
+
 						case reflect.Slice:
 							switch reflect.Indirect(rv).Field(i).Type().Elem().Kind() {
 							case reflect.Int:
@@ -231,6 +243,9 @@ func (c *Config) parse(lines []Line, opts interface{}) error {
 								}
 
 								reflect.Indirect(rv).Field(i).Set(reflect.ValueOf(nums))
+
+							case reflect.String:
+								reflect.Indirect(rv).Field(i).Set(reflect.ValueOf(fields[1:]))
 							}
 						}
 					}
@@ -9,7 +9,9 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (c *Config) ParseFS() (vfs.VFS, error) {
+// ParseFSOpts parses the fs namespace without opening anything, used on its
+// own when reloading
+func (c *Config) ParseFSOpts() (*vfs.FilesystemOpts, error) {
 	var opts vfs.FilesystemOpts
 
 	lines, ok := c.lines[NamespaceFS]
@@ -37,6 +39,15 @@ func (c *Config) ParseFS() (vfs.VFS, error) {
 		opts.SetHideRE(re)
 	}
 
+	return &opts, nil
+}
+
+func (c *Config) ParseFS() (vfs.VFS, error) {
+	opts, err := c.ParseFSOpts()
+	if err != nil {
+		return nil, err
+	}
+
 	ufs := osfs.New(opts.Root)
 
 	opt := badger.DefaultOptions(opts.ShadowDB)
@@ -55,7 +66,7 @@ func (c *Config) ParseFS() (vfs.VFS, error) {
 		return nil, err
 	}
 
-	fs, err := vfs.NewFilesystem(&opts, ufs, shadowFS, perms)
+	fs, err := vfs.NewFilesystem(opts, ufs, shadowFS, perms)
 	if err != nil {
 		return nil, err
 	}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"crypto/tls"
+	"strconv"
+	"strings"
 
+	"github.com/goftpd/goftpd/acl"
 	"github.com/goftpd/goftpd/ftp"
 	"github.com/pkg/errors"
 )
@@ -45,6 +48,34 @@ func (c *Config) ParseServerOpts() (*ftp.ServerOpts, error) {
 		opts.Port = 2121
 	}
 
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = 900
+	}
+
+	if opts.LoginTimeout == 0 {
+		opts.LoginTimeout = 60
+	}
+
+	if opts.DataAcceptTimeout == 0 {
+		opts.DataAcceptTimeout = 60
+	}
+
+	if opts.DataConnectTimeout == 0 {
+		opts.DataConnectTimeout = 60
+	}
+
+	if opts.StallTimeout == 0 {
+		opts.StallTimeout = 60
+	}
+
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = 30
+	}
+
+	if opts.ImplicitTLSPort == opts.Port {
+		return nil, errors.New("implicit_tls_port must be different to port")
+	}
+
 	if len(opts.PassivePorts) != 2 {
 		opts.PassivePorts = []int{
 			20000,
@@ -56,6 +87,49 @@ func (c *Config) ParseServerOpts() (*ftp.ServerOpts, error) {
 		return nil, errors.New("Passvive Ports must be in order: min,max")
 	}
 
+	if len(opts.LimitsExempt) > 0 {
+		exempt, err := acl.NewFromString(opts.LimitsExempt)
+		if err != nil {
+			return nil, errors.WithMessage(err, "limits_exempt")
+		}
+		opts.SetLimitsExempt(exempt)
+	}
+
+	siteACLs := []struct {
+		command string
+		rule    string
+	}{
+		{"WHO", opts.SiteWho},
+		{"SWHO", opts.SiteSWho},
+		{"KICK", opts.SiteKick},
+		{"KILL", opts.SiteKill},
+		{"IDLE", opts.SiteIdle},
+	}
+
+	for _, s := range siteACLs {
+		if len(s.rule) == 0 {
+			continue
+		}
+
+		a, err := acl.NewFromString(s.rule)
+		if err != nil {
+			return nil, errors.WithMessage(err, "site_"+strings.ToLower(s.command))
+		}
+		opts.SetSiteACL(s.command, a)
+	}
+
+	listeners, err := c.parseListeners(lines, &opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Listeners = listeners
+
+	pathSpeeds, err := c.parsePathSpeeds(lines)
+	if err != nil {
+		return nil, err
+	}
+	opts.PathSpeeds = pathSpeeds
+
 	// setup tlsConfig
 	tlsConfig := &tls.Config{}
 
@@ -73,3 +147,121 @@ func (c *Config) ParseServerOpts() (*ftp.ServerOpts, error) {
 	return &opts, nil
 
 }
+
+// parseListeners reads any `server listener <name> <setting> <value>` lines.
+// Settings that aren't given are taken from the server options
+func (c *Config) parseListeners(lines []Line, opts *ftp.ServerOpts) ([]*ftp.ListenerOpts, error) {
+	var names []string
+	listenerLines := make(map[string][]Line, 0)
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "listener" {
+			continue
+		}
+
+		if len(fields) < 4 {
+			return nil, errors.Errorf("error on line %d: expected listener <name> <setting> <value>", l.line)
+		}
+
+		name := fields[1]
+		if _, ok := listenerLines[name]; !ok {
+			names = append(names, name)
+		}
+
+		listenerLines[name] = append(listenerLines[name], Line{
+			text: strings.Join(fields[2:], " "),
+			line: l.line,
+		})
+	}
+
+	var listeners []*ftp.ListenerOpts
+
+	ports := make(map[int]string, 0)
+
+	for _, name := range names {
+		lo := ftp.ListenerOpts{Name: name}
+
+		if err := c.parse(listenerLines[name], &lo); err != nil {
+			return nil, err
+		}
+
+		if lo.Port == 0 {
+			return nil, errors.Errorf("listener '%s': port required", name)
+		}
+
+		if other, ok := ports[lo.Port]; ok {
+			return nil, errors.Errorf("listener '%s': port %d already used by '%s'", name, lo.Port, other)
+		}
+		ports[lo.Port] = name
+
+		if lo.ImplicitTLS && lo.AllowPlaintext {
+			return nil, errors.Errorf("listener '%s': implicit_tls and allow_plaintext can't both be set", name)
+		}
+
+		if len(lo.Host) == 0 {
+			lo.Host = opts.Host
+		}
+
+		if len(lo.PassivePorts) == 0 {
+			lo.PassivePorts = opts.PassivePorts
+		}
+
+		if len(lo.PublicIP) == 0 {
+			lo.PublicIP = opts.PublicIP
+		}
+
+		if len(lo.BindIP) == 0 {
+			lo.BindIP = opts.BindIP
+		}
+
+		if len(lo.ProxyFrom) == 0 {
+			lo.ProxyFrom = opts.ProxyFrom
+		}
+
+		if len(lo.IdntFrom) == 0 {
+			lo.IdntFrom = opts.IdntFrom
+		}
+
+		listeners = append(listeners, &lo)
+	}
+
+	return listeners, nil
+}
+
+// parsePathSpeeds reads any `server path_speed <path> <download> <upload>`
+// lines, the speeds are in KB/s and 0 is unlimited
+func (c *Config) parsePathSpeeds(lines []Line) ([]*ftp.PathSpeedOpts, error) {
+	var pathSpeeds []*ftp.PathSpeedOpts
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "path_speed" {
+			continue
+		}
+
+		if len(fields) != 4 {
+			return nil, errors.Errorf("error on line %d: expected path_speed <path> <download> <upload>", l.line)
+		}
+
+		download, err := strconv.Atoi(fields[2])
+		if err != nil || download < 0 {
+			return nil, errors.Errorf("error parsing path_speed on line %d: download is not a number", l.line)
+		}
+
+		upload, err := strconv.Atoi(fields[3])
+		if err != nil || upload < 0 {
+			return nil, errors.Errorf("error parsing path_speed on line %d: upload is not a number", l.line)
+		}
+
+		pathSpeeds = append(pathSpeeds, &ftp.PathSpeedOpts{
+			Path:     fields[1],
+			Download: download,
+			Upload:   upload,
+		})
+	}
+
+	return pathSpeeds, nil
+}
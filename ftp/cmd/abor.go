@@ -39,16 +39,22 @@ func (c commandABOR) RequireState() SessionState { return SessionStateLoggedIn }
 
 func (c commandABOR) Execute(ctx context.Context, s Session, params []string) error {
 
-	// check if we have an existing data conncetion, if so cancel it
+	// the transfer replies with a 426 once it has been aborted, we then
+	// follow up with the 226
+	if t := s.Transfer(); t != nil {
+		t.Abort()
+
+		s.ReplyWithMessage(StatusDataClosedOK, "ABOR command successful.")
+		return nil
+	}
+
+	// otherwise close any data connection that is open but unused
 	if s.Data() != nil {
 		if err := s.Data().Close(); err != nil {
 			s.ReplyError(StatusCantOpenDataConnection, err)
 			return nil
 		}
 
-		s.ReplyStatus(StatusDataCloseAborted)
-
-		// TODO: might be a race condition here
 		s.ClearData()
 	}
 
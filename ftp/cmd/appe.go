@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
 )
 
 /*
@@ -40,6 +43,28 @@ func (c commandAPPE) Execute(ctx context.Context, s Session, params []string) er
 		return errors.New("no user found")
 	}
 
+	release, err := s.ReserveTransfer(user, true)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+	defer release()
+
+	// append to whatever is there already, nothing if it doesn't exist yet
+	var writer io.WriteCloser
+
+	offset, err := s.FS().Size(path)
+	if err != nil {
+		writer, err = s.FS().UploadFile(path, user)
+	} else {
+		writer, err = s.FS().ResumeUploadFile(path, offset, user)
+	}
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+	defer writer.Close()
+
 	if s.DataProtected() {
 		s.ReplyWithMessage(StatusTransferStatusOK, "Opening connection for upload using TLS/SSL.")
 		if err := s.Flush(); err != nil {
@@ -54,21 +79,36 @@ func (c commandAPPE) Execute(ctx context.Context, s Session, params []string) er
 	defer s.Data().Close()
 	defer s.ClearData()
 
-	writer, err := s.FS().ResumeUploadFile(path, user)
-	if err != nil {
-		s.ReplyError(StatusActionNotOK, err)
-		return nil
+	buf := s.FS().GetBuffer()
+	defer s.FS().PutBuffer(buf)
+
+	started := time.Now()
+
+	n, copyErr := io.CopyBuffer(writer, s.Data(), *buf)
+
+	s.Data().Close()
+
+	if err := writer.Close(); err != nil && copyErr == nil {
+		copyErr = err
 	}
 
-	n, err := io.Copy(writer, s.Data())
-	if err != nil {
-		s.ReplyError(StatusActionNotOK, err)
-		return nil
+	if n > 1024 {
+		kb := n / 1024
+		go func() {
+			s.Auth().UpdateUser(user.Name, func(u *acl.User) error {
+				u.Credits += kb * int64(u.Ratio)
+				return nil
+			})
+		}()
 	}
 
-	// TODO bring this in line with STOR
+	if copyErr != nil {
+		s.RecordTransfer(newTransferStats(s, "APPE", path, n, started, false))
+		s.ReplyError(StatusActionNotOK, copyErr)
+		return nil
+	}
 
-	s.ClearData()
+	s.RecordTransfer(newTransferStats(s, "APPE", path, n, started, true))
 
 	s.ReplyWithMessage(StatusDataClosedOK, fmt.Sprintf("OK, received %d bytes.", n))
 	return nil
@@ -76,4 +116,5 @@ func (c commandAPPE) Execute(ctx context.Context, s Session, params []string) er
 
 func init() {
 	CommandMap["APPE"] = &commandAPPE{}
+	TransferCommands["APPE"] = true
 }
@@ -60,6 +60,13 @@ func (c commandAUTH) Execute(ctx context.Context, s Session, params []string) er
 		return nil
 	}
 
+	// the control connection is already TLS, either from an earlier AUTH
+	// or an implicit TLS port, or the session logged in without it
+	if s.State() >= SessionStateAuth {
+		s.ReplyStatus(StatusBadCommandSequence)
+		return nil
+	}
+
 	if strings.ToUpper(params[0]) != "TLS" {
 		s.ReplyWithMessage(
 			StatusParameterNotImplemented,
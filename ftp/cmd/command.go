@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net"
+	"time"
 
 	"github.com/goftpd/goftpd/acl"
 	"github.com/goftpd/goftpd/vfs"
@@ -41,11 +42,107 @@ type DataConn interface {
 	BytesRead() int
 	BytesWritten() int
 
+	// what actually went over the connection, differs from the above
+	// when the data is compressed
+	WireBytesRead() int
+	WireBytesWritten() int
+
 	io.Writer
 	io.Reader
 	io.Closer
 }
 
+// ZeroCopier is implemented by data connections that can send a file without
+// copying it through userspace, using sendfile on a plain TCP connection. If
+// sent is false nothing was written and the caller should copy r itself
+type ZeroCopier interface {
+	ZeroCopy(r io.Reader) (n int64, sent bool, err error)
+}
+
+// Transfer is a command using the data connection that the session is
+// running in the background, leaving the control channel free for ABOR
+// and STAT
+type Transfer interface {
+	Command() string
+	Data() DataConn
+	Started() time.Time
+
+	// Abort closes the data connection and waits for the command to
+	// finish, it will have replied with a 426
+	Abort()
+
+	// set once the command has completed, for post hooks
+	Stats() *TransferStats
+}
+
+// TransferStats describes a RETR, STOR or APPE once its data connection is
+// done with. Post hooks get completed ones as transfer and SITE scripts can
+// read the last one from the session
+type TransferStats struct {
+	Command  string
+	Path     string
+	Bytes    int64
+	Duration time.Duration
+	Complete bool
+
+	// TYPE I, otherwise it was sent as TYPE A
+	Binary bool
+
+	// average bytes a second
+	Speed float64
+
+	// the data connection's Kind, and whether it was protected
+	Kind string
+	TLS  bool
+}
+
+// Seconds is the duration as a number, for scripts
+func (t TransferStats) Seconds() float64 { return t.Duration.Seconds() }
+
+// KBps is the average speed in KB/s, the unit zipscripts expect
+func (t TransferStats) KBps() float64 { return t.Speed / 1024 }
+
+// newTransferStats is called by transfer commands once the data connection
+// is done with, started being when they began copying
+func newTransferStats(s Session, command, path string, n int64, started time.Time, complete bool) TransferStats {
+	t := TransferStats{
+		Command:  command,
+		Path:     path,
+		Bytes:    n,
+		Duration: time.Since(started),
+		Complete: complete,
+		Binary:   s.BinaryMode(),
+		Kind:     s.Data().Kind(),
+		TLS:      s.DataProtected(),
+	}
+
+	if secs := t.Duration.Seconds(); secs > 0 {
+		t.Speed = float64(n) / secs
+	}
+
+	return t
+}
+
+// SessionInfo is what other sessions can see of a session, for SITE WHO
+type SessionInfo struct {
+	ID         uint64
+	User       string
+	Group      string
+	RemoteAddr string
+	Ident      string
+	Command    string
+	CWD        string
+	Connected  time.Time
+	Idle       time.Duration
+
+	// set while a transfer is running. Direction is upload, download or
+	// list, Speed is bytes a second since it started
+	Direction string
+	Path      string
+	Bytes     int64
+	Speed     float64
+}
+
 type SessionState int
 
 const (
@@ -75,12 +172,16 @@ type Session interface {
 
 	// control
 	Control() net.Conn
+	RemoteAddr() net.Addr
+	SetRemoteAddr(net.Addr)
 
 	// data
 	Data() DataConn
 	ClearData()
-	NewPassiveDataConn(context.Context) error
-	NewActiveDataConn(context.Context, string) error
+	// the bool swaps our TLS role to client, see SSCN and CPSV
+	NewPassiveDataConn(context.Context, bool) error
+	NewActiveDataConn(context.Context, string, bool) error
+	Transfer() Transfer
 
 	// state do we want to store this inside the context?
 	State() SessionState
@@ -89,12 +190,36 @@ type Session interface {
 	SetBinaryMode(bool)
 	BinaryMode() bool
 
+	SetTransferMode(TransferMode)
+	TransferMode() TransferMode
+
+	SetDeflateLevel(int)
+	DeflateLevel() int
+
 	SetDataProtected(bool)
 	DataProtected() bool
 
+	SetSSCN(bool)
+	SSCN() bool
+
+	SetEPSVAll(bool)
+	EPSVAll() bool
+
+	SetMLSTFacts([]string)
+	MLSTFacts() []string
+
+	SetHashAlgorithm(string)
+	HashAlgorithm() string
+
+	SetRange(int64, int64)
+	Range() (int64, int64)
+
 	SetRestartPosition(int)
 	RestartPosition() int
 
+	SetPRET(string, string)
+	PRET() (string, string)
+
 	SetRenameFrom([]string)
 	RenameFrom() []string
 
@@ -104,9 +229,38 @@ type Session interface {
 	SetLogin(string)
 	Login() string
 
+	// IDNT
+	TrustedBouncer() bool
+	SetIdent(string)
+	Ident() string
+	SetHostname(string)
+	Hostname() string
+
 	User() *acl.User
+	// checks the login limits and counts the session towards them
+	ReserveLogin(*acl.User) error
+	// takes an upload (true) or download slot, call the func to give it back
+	ReserveTransfer(*acl.User, bool) (func(), error)
 
 	LastCommand() string
+
+	// the last RETR, STOR or APPE to complete, nil if there hasn't been one.
+	// RecordTransfer is called by them whether they completed or not
+	LastTransfer() *TransferStats
+	RecordTransfer(TransferStats)
+
+	// seconds the session can be idle for, SITE IDLE can lower it as far
+	// as MaxIdleTimeout
+	IdleTimeout() int
+	SetIdleTimeout(int)
+	MaxIdleTimeout() int
+
+	// the session registry, for SITE WHO, KICK and KILL. KickUser and
+	// KillSession leave the calling session alone
+	ID() uint64
+	Sessions() []SessionInfo
+	KickUser(user, reason string) int
+	KillSession(id uint64, reason string) error
 }
 
 type Command interface {
@@ -115,4 +269,12 @@ type Command interface {
 }
 
 var CommandMap = map[string]Command{}
+
+// SiteCommandMap holds the SITE commands handled natively, keyed by the word
+// after SITE. Any other SITE command is left to scripts
+var SiteCommandMap = map[string]Command{}
+
+// TransferCommands are run in the background when the session has a data
+// connection, commands register themselves in their init
+var TransferCommands = map[string]bool{}
 var featSlice = []string{}
@@ -0,0 +1,14 @@
+package cmd
+
+/*
+   CLIENT PASSIVE (CPSV)
+
+      The same as PASV except that this server acts as the TLS client on
+      the data connection, regardless of SSCN. Used by FXP clients so the
+      two servers can negotiate TLS between themselves.
+*/
+
+func init() {
+	CommandMap["CPSV"] = &commandPASV{tlsClient: true}
+	featSlice = append(featSlice, "CPSV")
+}
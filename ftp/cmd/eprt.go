@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/*
+   EXTENDED PORT (EPRT)
+
+      The EPRT command allows for the specification of an extended address
+      for the data connection.  The extended address MUST consist of the
+      network protocol as well as the network and transport addresses.  The
+      format of EPRT is:
+
+           EPRT<space><d><net-prt><d><net-addr><d><tcp-port><d>
+
+      The EPRT command keyword MUST be followed by a single space (ASCII
+      32).  Following the space, a delimiter character (<d>) MUST be
+      specified.  The delimiter character MUST be one of the ASCII
+      characters in range 33-126 inclusive.  The character "|" (ASCII 124)
+      is recommended unless it coincides with a character needed to encode
+      the network address.
+
+      The following are sample EPRT commands:
+
+           EPRT |1|132.235.1.2|6275|
+
+           EPRT |2|1080::8:800:200C:417A|5282|
+
+      If the server doesn't support the requested network protocol, it
+      MUST return error code 522.
+*/
+
+var (
+	errEPRTSyntax   = errors.New("expected <d><net-prt><d><net-addr><d><tcp-port><d>")
+	errEPRTProtocol = errors.New("unsupported network protocol")
+)
+
+type commandEPRT struct{}
+
+func (c commandEPRT) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandEPRT) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	if s.EPSVAll() {
+		s.ReplyWithMessage(StatusBadCommandSequence, "EPRT not allowed after EPSV ALL.")
+		return nil
+	}
+
+	addr, err := c.parse(params[0])
+	if err != nil {
+		if err == errEPRTProtocol {
+			s.ReplyStatus(StatusNetworkProtocolNotSupported)
+			return nil
+		}
+		s.ReplyError(StatusSyntaxError, err)
+		return nil
+	}
+
+	// check if we have an existing data conncetion, if so cancel it
+	if s.Data() != nil {
+		if err := s.Data().Close(); err != nil {
+			s.ReplyError(StatusCantOpenDataConnection, err)
+			return nil
+		}
+	}
+
+	// create new active data connection
+	if err := s.NewActiveDataConn(ctx, addr, s.SSCN()); err != nil {
+		s.ReplyError(StatusCantOpenDataConnection, err)
+		return nil
+	}
+
+	s.ReplyWithMessage(StatusOK, fmt.Sprintf("Connection established to (%s)", params[0]))
+	return nil
+}
+
+// parse takes an EPRT argument and returns it as a host:port address, making
+// sure that the address matches the network protocol
+func (c commandEPRT) parse(param string) (string, error) {
+	if len(param) < 7 {
+		return "", errEPRTSyntax
+	}
+
+	d := param[0]
+	if d < 33 || d > 126 || param[len(param)-1] != d {
+		return "", errEPRTSyntax
+	}
+
+	parts := strings.Split(param[1:len(param)-1], string(d))
+	if len(parts) != 3 {
+		return "", errEPRTSyntax
+	}
+
+	ip := net.ParseIP(parts[1])
+	if ip == nil {
+		return "", fmt.Errorf("bad address '%s'", parts[1])
+	}
+
+	switch parts[0] {
+	case "1":
+		if ip.To4() == nil {
+			return "", fmt.Errorf("'%s' is not an IPv4 address", parts[1])
+		}
+	case "2":
+		if ip.To4() != nil {
+			return "", fmt.Errorf("'%s' is not an IPv6 address", parts[1])
+		}
+	default:
+		return "", errEPRTProtocol
+	}
+
+	port, err := strconv.Atoi(parts[2])
+	if err != nil || port < 1 || port > 65535 {
+		return "", fmt.Errorf("bad port '%s'", parts[2])
+	}
+
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port)), nil
+}
+
+func init() {
+	CommandMap["EPRT"] = &commandEPRT{}
+	featSlice = append(featSlice, "EPRT")
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+/*
+   EXTENDED PASSIVE (EPSV)
+
+      The EPSV command requests that a server listen on a data port and
+      wait for a connection.  The EPSV command takes an optional argument.
+      The response to this command includes only the TCP port number of the
+      listening connection.  The format of the response, however, is
+      similar to the argument of the EPRT command.  This allows the same
+      parsing routines to be used for both commands.  In addition, the
+      format leaves a place holder for the network protocol and/or network
+      address, which may be needed in the EPSV response in the future.  The
+      response code for entering passive mode using an extended address
+      MUST be 229.
+
+      When the EPSV command is issued with no argument, the server will
+      choose the network protocol for the data connection based on the
+      protocol used for the control connection.
+
+      The EPSV command can also be used to request that the server
+      use a particular network protocol with the syntax "EPSV <net-prt>".
+      If the requested protocol is not supported by the server it
+      MUST respond with a 522 error.
+
+      Finally, the EPSV command can be used with the argument "ALL" to
+      inform Network Address Translators that the EPRT command (as well
+      as other data commands) will no longer be used.  Upon receipt of
+      an EPSV ALL command, the server MUST reject all data connection
+      setup commands other than EPSV (i.e., EPRT, PORT, PASV, et al.).
+*/
+
+type commandEPSV struct{}
+
+func (c commandEPSV) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandEPSV) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) > 1 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	if len(params) == 1 {
+		switch strings.ToUpper(params[0]) {
+		case "ALL":
+			s.SetEPSVAll(true)
+			s.ReplyWithMessage(StatusOK, "EPSV ALL command successful.")
+			return nil
+
+		case "1", "2":
+			// the client connects to the address it used for the control
+			// connection, so the data connection has to be the same family
+			if family := addrFamily(s.Control().LocalAddr()); params[0] != family {
+				s.ReplyWithMessage(
+					StatusNetworkProtocolNotSupported,
+					fmt.Sprintf("Network protocol not supported, use (%s).", family),
+				)
+				return nil
+			}
+
+		default:
+			s.ReplyStatus(StatusNetworkProtocolNotSupported)
+			return nil
+		}
+	}
+
+	// check if we have an existing data conncetion, if so cancel it
+	if s.Data() != nil {
+		if err := s.Data().Close(); err != nil {
+			s.ReplyError(StatusCantOpenDataConnection, err)
+			return nil
+		}
+	}
+
+	// create new passive data connection
+	if err := s.NewPassiveDataConn(ctx, s.SSCN()); err != nil {
+		s.ReplyError(StatusCantOpenDataConnection, err)
+		return nil
+	}
+
+	s.ReplyWithArgs(StatusExtendedPassiveMode, s.Data().Port())
+	return nil
+}
+
+// addrFamily returns the RFC 2428 network protocol of the address, 1 for IPv4
+// and 2 for IPv6
+func addrFamily(addr net.Addr) string {
+	if tcp, ok := addr.(*net.TCPAddr); ok && tcp.IP.To4() == nil {
+		return "2"
+	}
+	return "1"
+}
+
+func init() {
+	CommandMap["EPSV"] = &commandEPSV{}
+	featSlice = append(featSlice, "EPSV")
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/vfs"
+)
+
+/*
+   FILE HASH (HASH)
+
+      The HASH command allows for requesting the hash of a file. The
+      algorithm used is selected with OPTS HASH and the range of the file
+      can be limited with RANG.
+
+         HASH <SP> <pathname> <CRLF>
+
+      The response contains the algorithm, the byte range that was hashed,
+      the hash as hex and the pathname. The end point of the range is
+      exclusive, so the whole file is 0 to its size:
+
+         213 <SP> <hash-name> <SP> <start-point> "-" <end-point> <SP>
+             <file-hash> <SP> <pathname> <CRLF>
+
+      The currently selected algorithm is marked in the FEAT response with
+      an asterisk. If the file can not be found or the user does not have
+      permission a 550 reply is returned.
+*/
+
+type commandHASH struct{}
+
+func (c commandHASH) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandHASH) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	// RANG only applies to the next command
+	start, end := s.Range()
+	defer s.SetRange(0, 0)
+
+	path := s.FS().Join(s.CWD(), params)
+
+	sum, err := s.FS().HashFile(path, s.HashAlgorithm(), start, end, user)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	size, err := s.FS().Size(path)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	// the end is exclusive, a range past the end of the file stops at the
+	// end of it
+	if end == 0 || end > size {
+		end = size
+	}
+
+	s.ReplyWithMessage(
+		StatusFileStatus,
+		fmt.Sprintf("%s %d-%d %s %s", s.HashAlgorithm(), start, end, hex.EncodeToString(sum), path),
+	)
+	return nil
+}
+
+// optsHASH handles OPTS HASH, with no params it returns the current
+// algorithm otherwise it tries to select the given algorithm
+func optsHASH(s Session, params []string) {
+	if len(params) == 0 {
+		s.ReplyWithMessage(StatusOK, s.HashAlgorithm())
+		return
+	}
+
+	if len(params) > 1 {
+		s.ReplyStatus(StatusSyntaxError)
+		return
+	}
+
+	for _, a := range vfs.HashAlgorithms {
+		if strings.EqualFold(a, params[0]) {
+			s.SetHashAlgorithm(a)
+			s.ReplyWithMessage(StatusOK, a)
+			return
+		}
+	}
+
+	s.ReplyWithMessage(StatusSyntaxError, "Unknown algorithm, current selection not changed.")
+}
+
+func init() {
+	CommandMap["HASH"] = &commandHASH{}
+	optsMap["HASH"] = optsHASH
+
+	feat := make([]string, len(vfs.HashAlgorithms))
+	for idx, a := range vfs.HashAlgorithms {
+		feat[idx] = a
+		if a == vfs.HashSHA256 {
+			feat[idx] += "*"
+		}
+	}
+	featSlice = append(featSlice, "HASH "+strings.Join(feat, ";"))
+}
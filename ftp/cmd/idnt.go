@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+/*
+   IDENT (IDNT)
+
+      Sent by a bouncer before USER to pass on the identity of the
+      client connected to it, as glftpd does. Only accepted from the
+      bouncer addresses set by idnt_from, anyone else gets the same
+      reply as an unknown command.
+
+         IDNT <SP> <ident>@<ip>:<hostname> <CRLF>
+
+      IPv6 addresses followed by a hostname are put in brackets,
+      [<ip>]:<hostname>, the hostname can be left out.
+
+      The ident and ip are used to check the user's masks in place of
+      the bouncer's address, and no ident query is made. An unknown
+      ident is sent as *. Like glftpd nothing is sent back on success,
+      bouncers carry on straight away with USER.
+*/
+
+type commandIDNT struct{}
+
+func (c commandIDNT) RequireState() SessionState { return SessionStateNull }
+
+func (c commandIDNT) Execute(ctx context.Context, s Session, params []string) error {
+	if !s.TrustedBouncer() {
+		s.ReplyStatus(StatusNotImplemented)
+		return nil
+	}
+
+	if len(params) != 1 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	// only once, before logging in
+	if len(s.Ident()) > 0 || len(s.Login()) > 0 || s.State() == SessionStateLoggedIn {
+		s.ReplyStatus(StatusBadCommandSequence)
+		return nil
+	}
+
+	at := strings.Index(params[0], "@")
+	if at < 0 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	ident := params[0][:at]
+	host := params[0][at+1:]
+
+	ip, hostname, ok := parseIdntHost(host)
+	if !ok {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	if len(ident) == 0 {
+		ident = "*"
+	}
+
+	s.SetIdent(ident)
+	s.SetHostname(hostname)
+	s.SetRemoteAddr(&net.TCPAddr{IP: ip})
+
+	return nil
+}
+
+// parseIdntHost splits the ip and optional hostname. IPv6 addresses have
+// colons of their own, so a hostname can only follow one in brackets. Without
+// brackets the hostname is whatever follows the last colon as long as what
+// comes before it is an address
+func parseIdntHost(host string) (net.IP, string, bool) {
+	if strings.HasPrefix(host, "[") {
+		end := strings.Index(host, "]")
+		if end < 0 {
+			return nil, "", false
+		}
+
+		ip := net.ParseIP(host[1:end])
+		rest := host[end+1:]
+
+		switch {
+		case ip == nil:
+			return nil, "", false
+		case len(rest) == 0:
+			return ip, "", true
+		case rest[0] == ':':
+			return ip, rest[1:], true
+		default:
+			return nil, "", false
+		}
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, "", true
+	}
+
+	if idx := strings.LastIndex(host, ":"); idx >= 0 {
+		if ip := net.ParseIP(host[:idx]); ip != nil {
+			return ip, host[idx+1:], true
+		}
+	}
+
+	return nil, "", false
+}
+
+func init() {
+	CommandMap["IDNT"] = &commandIDNT{}
+}
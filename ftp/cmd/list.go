@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 /*
@@ -28,10 +29,6 @@ type commandLIST struct{}
 func (c commandLIST) RequireState() SessionState { return SessionStateLoggedIn }
 
 func (c commandLIST) Execute(ctx context.Context, s Session, params []string) error {
-	if len(params) == 0 {
-		params = append(params, s.CWD())
-	}
-
 	if s.Data() == nil {
 		s.ReplyStatus(StatusCantOpenDataConnection)
 		return nil
@@ -42,33 +39,19 @@ func (c commandLIST) Execute(ctx context.Context, s Session, params []string) er
 		return errors.New("no user found")
 	}
 
-	var options, path string
+	opts, params := parseListParams(params)
 
-	// check if we have options and set the path eitherway
-	// check if we have options and set the path eitherway
-	if len(params[0]) > 0 && params[0][0] == '-' {
-		options = params[0]
-		if len(params) > 1 {
-			params = params[1:]
-		} else {
-			params = []string{}
-		}
-	}
+	// LIST is always detailed
+	opts.long = true
 
-	path = s.FS().Join(s.CWD(), params)
+	path := s.FS().Join(s.CWD(), params)
 
-	// get file list and parse with any options
-	finfo, err := s.FS().ListDir(path, user)
+	b, err := listing(s, user, path, strings.Join(params, " "), opts)
 	if err != nil {
 		s.ReplyError(StatusActionAbortedError, err)
 		return nil
 	}
 
-	if len(options) > 0 {
-		// handle options
-		finfo.SortByName()
-	}
-
 	if s.DataProtected() {
 		s.ReplyWithMessage(StatusTransferStatusOK, "Opening connection for directory listing using TLS/SSL.")
 		if err := s.Flush(); err != nil {
@@ -84,7 +67,7 @@ func (c commandLIST) Execute(ctx context.Context, s Session, params []string) er
 	defer s.ClearData()
 
 	// write it
-	n, err := s.Data().Write(finfo.Detailed())
+	n, err := s.Data().Write(b)
 	if err != nil {
 		s.ReplyError(StatusActionAbortedError, err)
 		return nil
@@ -99,4 +82,5 @@ func (c commandLIST) Execute(ctx context.Context, s Session, params []string) er
 
 func init() {
 	CommandMap["LIST"] = &commandLIST{}
+	TransferCommands["LIST"] = true
 }
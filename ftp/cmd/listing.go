@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// listRecursiveMaxDepth and listRecursiveMaxEntries cap how much of the
+// tree -R walks, so a listing from / can't take the site down
+const (
+	listRecursiveMaxDepth   = 8
+	listRecursiveMaxEntries = 10000
+)
+
+// listOptions are the ls style options supported by LIST, NLST and STAT.
+// Unknown options are ignored, including -a as entries starting with a .
+// are always shown
+type listOptions struct {
+	long      bool // -l detailed listing, LIST and STAT always are
+	recursive bool // -R list subdirectories too
+	byTime    bool // -t sort by modification time, newest first
+	bySize    bool // -S sort by size, largest first
+	reverse   bool // -r reverse the sort
+}
+
+// parseListParams takes any leading options from params, returning the
+// options and the remaining params, which make up the path
+func parseListParams(params []string) (listOptions, []string) {
+	var opts listOptions
+
+	for len(params) > 0 && len(params[0]) > 1 && params[0][0] == '-' {
+		for _, o := range params[0][1:] {
+			switch o {
+			case 'l':
+				opts.long = true
+			case 'R':
+				opts.recursive = true
+			case 't':
+				opts.byTime = true
+			case 'S':
+				opts.bySize = true
+			case 'r':
+				opts.reverse = true
+			}
+		}
+		params = params[1:]
+	}
+
+	return opts, params
+}
+
+// sort orders the list using the options, -S wins over -t like it does in ls
+func (o listOptions) sort(flist vfs.FileList) {
+	flist.SortByName()
+
+	if o.bySize {
+		flist.SortBySize()
+	} else if o.byTime {
+		flist.SortByTime()
+	}
+
+	if o.reverse {
+		flist.Reverse()
+	}
+}
+
+// match removes any entries not matching the glob pattern
+func match(flist vfs.FileList, pattern string) vfs.FileList {
+	results := flist[:0]
+
+	for _, f := range flist {
+		if ok, _ := filepath.Match(pattern, f.Name()); ok {
+			results = append(results, f)
+		}
+	}
+
+	return results
+}
+
+// isGlob checks to see if the name should be treated as a pattern
+func isGlob(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// listing creates the output for LIST, NLST and STAT. The path can be a
+// directory, a single file or a glob on the last element. The name is what
+// the client asked for and is used for headers in recursive listings
+func listing(s Session, user *acl.User, path, name string, opts listOptions) ([]byte, error) {
+	format := func(flist vfs.FileList) []byte {
+		if opts.long {
+			return flist.Detailed()
+		}
+		return flist.Short()
+	}
+
+	list := func(path string, flist vfs.FileList) []byte {
+		opts.sort(flist)
+
+		if !opts.recursive {
+			return format(flist)
+		}
+
+		if len(name) == 0 {
+			name = "."
+		}
+
+		remaining := listRecursiveMaxEntries - len(flist)
+
+		var buf bytes.Buffer
+		listRecursive(s, user, path, name, flist, opts, format, 1, &remaining, &buf)
+		return buf.Bytes()
+	}
+
+	// a glob lists matching entries from the parent, when nothing matches
+	// the name is used as it is so e.g. Foo[2020] can still be listed
+	if base := filepath.Base(path); isGlob(base) {
+		dir := filepath.Dir(path)
+
+		if flist, err := s.FS().ListDir(dir, user); err == nil {
+			if flist = match(flist, base); len(flist) > 0 {
+				return list(dir, flist), nil
+			}
+		}
+	}
+
+	finfo, err := s.FS().Stat(path, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if !finfo.IsDir() {
+		return format(vfs.FileList{finfo}), nil
+	}
+
+	flist, err := s.FS().ListDir(path, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return list(path, flist), nil
+}
+
+// listRecursive writes the listing for a directory and then for each of its
+// subdirectories in the same way as ls -R. Subdirectories we can't list are
+// skipped, as is anything past listRecursiveMaxDepth or once
+// listRecursiveMaxEntries entries have been listed
+func listRecursive(s Session, user *acl.User, path, name string, flist vfs.FileList, opts listOptions, format func(vfs.FileList) []byte, depth int, remaining *int, buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "%s:\n", name)
+	buf.Write(format(flist))
+
+	if depth >= listRecursiveMaxDepth {
+		return
+	}
+
+	for _, f := range flist {
+		if !f.IsDir() {
+			continue
+		}
+
+		if *remaining <= 0 {
+			return
+		}
+
+		subpath := filepath.Join(path, f.Name())
+
+		sublist, err := s.FS().ListDir(subpath, user)
+		if err != nil {
+			continue
+		}
+
+		opts.sort(sublist)
+
+		if len(sublist) > *remaining {
+			sublist = sublist[:*remaining]
+		}
+		*remaining -= len(sublist)
+
+		buf.WriteString("\n")
+		listRecursive(s, user, subpath, name+"/"+f.Name(), sublist, opts, format, depth+1, remaining, buf)
+	}
+}
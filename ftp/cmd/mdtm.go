@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+)
+
+/*
+   FILE MODIFICATION TIME (MDTM)
+
+      The FTP command, MODIFICATION TIME (MDTM), can be used to determine
+      when a file in the server NVFS was last modified.
+
+      The syntax of a time value is:
+
+           time-val       = 14DIGIT [ "." 1*DIGIT ]
+
+      The leading, mandatory, fourteen digits are to be interpreted as, in
+      order from the leftmost, four digits giving the year, with a range of
+      1000--9999, two digits giving the month of the year, with a range of
+      01--12, two digits giving the day of the month, with a range of
+      01--31, two digits giving the hour of the day, with a range of
+      00--23, two digits giving minutes past the hour, with a range of
+      00--59, and finally, two digits giving seconds past the minute, with
+      a range of 00--60 (with 60 being used only at a leap second).  Years
+      in the tenth century, and earlier, cannot be expressed.  This is not
+      considered a serious defect of the protocol.
+
+      Time values are always represented in UTC (GMT), and in the Gregorian
+      calendar regardless of what calendar may have been in use at the date
+      and time indicated at the location of the server-PI.
+*/
+
+// timeValFormat is the time-val format used by MDTM, MFMT and MLST
+const timeValFormat = "20060102150405"
+
+type commandMDTM struct{}
+
+func (c commandMDTM) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandMDTM) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	finfo, err := s.FS().Stat(path, user)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	if finfo.IsDir() {
+		s.ReplyWithMessage(StatusActionNotOK, "Not a plain file.")
+		return nil
+	}
+
+	s.ReplyWithMessage(StatusFileStatus, finfo.ModTime().UTC().Format(timeValFormat))
+	return nil
+}
+
+func init() {
+	CommandMap["MDTM"] = &commandMDTM{}
+	featSlice = append(featSlice, "MDTM")
+}
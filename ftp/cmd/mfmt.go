@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+   MODIFY FACT: MODIFICATION TIME (MFMT)
+
+      The MFMT command is used to modify a file's last modification time
+      fact. The syntax is:
+
+           MFMT <time-val> <pathname>
+
+      The time-val is in the same format as returned by MDTM and is always
+      UTC. If the fact was changed the server responds with:
+
+           213 Modify=<time-val>; <pathname>
+
+      Otherwise a 550 reply is returned if the file does not exist or the
+      user does not have permission, and 501 if the time-val can not be
+      parsed.
+*/
+
+type commandMFMT struct{}
+
+func (c commandMFMT) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandMFMT) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) < 2 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	// ignore any fractions of a second
+	timeVal := params[0]
+	if len(timeVal) > len(timeValFormat) && timeVal[len(timeValFormat)] == '.' {
+		timeVal = timeVal[:len(timeValFormat)]
+	}
+
+	mtime, err := time.ParseInLocation(timeValFormat, timeVal, time.UTC)
+	if err != nil {
+		s.ReplyError(StatusSyntaxError, err)
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	path := s.FS().Join(s.CWD(), params[1:])
+
+	if err := s.FS().SetModTime(path, mtime, user); err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	s.ReplyWithMessage(StatusFileStatus, fmt.Sprintf("Modify=%s; %s", mtime.Format(timeValFormat), path))
+	return nil
+}
+
+func init() {
+	CommandMap["MFMT"] = &commandMFMT{}
+	featSlice = append(featSlice, "MFMT")
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+/*
+   LISTING FOR MACHINE PROCESSING (MLSD)
+
+      The MLSD command is intended to list the contents of a directory
+      over the data connection using the same fact format as MLST. The
+      pathname, if given, must be a directory, otherwise a 501 reply is
+      returned. Each entry is given on its own line containing the facts
+      followed by a single space and the name of the entry.
+*/
+
+type commandMLSD struct{}
+
+func (c commandMLSD) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandMLSD) Execute(ctx context.Context, s Session, params []string) error {
+	if s.Data() == nil {
+		s.ReplyStatus(StatusCantOpenDataConnection)
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	dir, err := s.FS().Stat(path, user)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	if !dir.IsDir() {
+		s.ReplyWithMessage(StatusSyntaxError, "Pathname is not a directory.")
+		return nil
+	}
+
+	finfo, err := s.FS().ListDir(path, user)
+	if err != nil {
+		s.ReplyError(StatusActionAbortedError, err)
+		return nil
+	}
+
+	var b strings.Builder
+	for _, f := range finfo {
+		b.WriteString(mlstFacts(s, user, filepath.Join(path, f.Name()), f))
+		b.WriteString(" ")
+		b.WriteString(f.Name())
+		b.WriteString("\r\n")
+	}
+
+	if s.DataProtected() {
+		s.ReplyWithMessage(StatusTransferStatusOK, "Opening connection for MLSD using TLS/SSL.")
+		if err := s.Flush(); err != nil {
+			return err
+		}
+	} else {
+		s.ReplyWithMessage(StatusTransferStatusOK, "Opening connection for MLSD.")
+		if err := s.Flush(); err != nil {
+			return err
+		}
+	}
+	defer s.Data().Close()
+	defer s.ClearData()
+
+	// write it
+	n, err := s.Data().Write([]byte(b.String()))
+	if err != nil {
+		s.ReplyError(StatusActionAbortedError, err)
+		return nil
+	}
+
+	s.Data().Close()
+
+	s.ReplyWithMessage(StatusDataClosedOK, fmt.Sprintf("Closing data connection, sent %d bytes", n))
+
+	return nil
+}
+
+func init() {
+	CommandMap["MLSD"] = &commandMLSD{}
+	TransferCommands["MLSD"] = true
+}
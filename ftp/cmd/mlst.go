@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+/*
+   LISTING FOR MACHINE PROCESSING (MLST)
+
+      The MLST and MLSD commands are intended to standardize the file and
+      directory information returned by the server-FTP process.  These
+      commands differ from the LIST command in that the format of the
+      replies is strictly defined although extensible.
+
+      The MLST command returns information about a single object over the
+      control connection. The pathname is optional, if absent the current
+      working directory is used.
+
+      Each fact is of the form fact-name "=" value ";" and the set of facts
+      is followed by a single space and the pathname. Fact names are case
+      insensitive. The facts returned can be selected with OPTS MLST.
+*/
+
+// DefaultMLSTFacts are the facts we support, and also the ones that are enabled
+// for a new session
+var DefaultMLSTFacts = []string{
+	"type",
+	"size",
+	"modify",
+	"unique",
+	"perm",
+	"UNIX.owner",
+	"UNIX.group",
+}
+
+type commandMLST struct{}
+
+func (c commandMLST) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandMLST) Execute(ctx context.Context, s Session, params []string) error {
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	finfo, err := s.FS().Stat(path, user)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	s.ReplyWithMessage(
+		StatusFileActionOK,
+		fmt.Sprintf(
+			"Listing %s\n %s %s",
+			path,
+			mlstFacts(s, user, path, finfo),
+			path,
+		),
+	)
+	return nil
+}
+
+// mlstFacts creates the fact string for a given path and FileInfo using the
+// sessions selected facts
+func mlstFacts(s Session, user *acl.User, path string, finfo vfs.FileInfo) string {
+	var b strings.Builder
+
+	for _, fact := range s.MLSTFacts() {
+		switch fact {
+		case "type":
+			if finfo.IsDir() {
+				b.WriteString("type=dir;")
+			} else {
+				b.WriteString("type=file;")
+			}
+
+		case "size":
+			if !finfo.IsDir() {
+				fmt.Fprintf(&b, "size=%d;", finfo.Size())
+			}
+
+		case "modify":
+			fmt.Fprintf(&b, "modify=%s;", finfo.ModTime().UTC().Format(timeValFormat))
+
+		case "unique":
+			// the shadow is keyed on the lower cased path so use the same
+			h := fnv.New64a()
+			h.Write([]byte(strings.ToLower(path)))
+			fmt.Fprintf(&b, "unique=%x;", h.Sum64())
+
+		case "perm":
+			fmt.Fprintf(&b, "perm=%s;", s.FS().PermissionFacts(path, finfo.IsDir(), user))
+
+		case "UNIX.owner":
+			fmt.Fprintf(&b, "UNIX.owner=%s;", finfo.Owner)
+
+		case "UNIX.group":
+			fmt.Fprintf(&b, "UNIX.group=%s;", finfo.Group)
+		}
+	}
+
+	return b.String()
+}
+
+// optsMLST handles OPTS MLST fact;fact; selecting which facts are returned, any
+// unknown facts are ignored
+func optsMLST(s Session, params []string) {
+	if len(params) > 1 {
+		s.ReplyStatus(StatusSyntaxError)
+		return
+	}
+
+	facts := make([]string, 0, len(DefaultMLSTFacts))
+
+	if len(params) == 1 {
+		for _, f := range strings.Split(params[0], ";") {
+			for _, d := range DefaultMLSTFacts {
+				if strings.EqualFold(f, d) {
+					facts = append(facts, d)
+					break
+				}
+			}
+		}
+	}
+
+	s.SetMLSTFacts(facts)
+
+	var b strings.Builder
+	for _, f := range facts {
+		b.WriteString(f)
+		b.WriteString(";")
+	}
+
+	s.ReplyWithMessage(StatusOK, fmt.Sprintf("MLST OPTS %s", b.String()))
+}
+
+func init() {
+	CommandMap["MLST"] = &commandMLST{}
+	optsMap["MLST"] = optsMLST
+
+	var b strings.Builder
+	b.WriteString("MLST ")
+	for _, f := range DefaultMLSTFacts {
+		b.WriteString(f)
+		b.WriteString("*;")
+	}
+	featSlice = append(featSlice, b.String())
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"compress/zlib"
 	"context"
+	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -22,8 +25,32 @@ import (
             The default transfer mode is Stream.
 
 			 MODE is obsolete. The server should accept MODE S (in any combination of lowercase and uppercase) with code 200, and reject all other MODE attempts with code 504.
+
+   DEFLATE TRANSMISSION MODE (MODE Z)
+
+      In MODE Z all data is sent as a zlib compressed stream, which is
+      finished before the data connection is closed. The compression
+      level can be set with OPTS MODE Z LEVEL <level>, where level is
+      between 0 and 9.
 */
 
+type TransferMode int
+
+const (
+	TransferModeStream TransferMode = iota
+	TransferModeDeflate
+)
+
+func (t TransferMode) String() string {
+	if t == TransferModeDeflate {
+		return "Deflate"
+	}
+	return "Stream"
+}
+
+// DefaultDeflateLevel is the compression level used for a new session
+const DefaultDeflateLevel = zlib.DefaultCompression
+
 type commandMODE struct{}
 
 func (c commandMODE) RequireState() SessionState { return SessionStateLoggedIn }
@@ -34,7 +61,14 @@ func (c commandMODE) Execute(ctx context.Context, s Session, params []string) er
 		return nil
 	}
 
-	if strings.ToUpper(params[0]) != "S" {
+	switch strings.ToUpper(params[0]) {
+	case "S":
+		s.SetTransferMode(TransferModeStream)
+
+	case "Z":
+		s.SetTransferMode(TransferModeDeflate)
+
+	default:
 		s.ReplyStatus(StatusParameterNotImplemented)
 		return nil
 	}
@@ -43,6 +77,26 @@ func (c commandMODE) Execute(ctx context.Context, s Session, params []string) er
 	return nil
 }
 
+// optsMODE handles OPTS MODE Z LEVEL <level>
+func optsMODE(s Session, params []string) {
+	if len(params) != 3 || strings.ToUpper(params[0]) != "Z" || strings.ToUpper(params[1]) != "LEVEL" {
+		s.ReplyStatus(StatusSyntaxError)
+		return
+	}
+
+	level, err := strconv.Atoi(params[2])
+	if err != nil || level < zlib.NoCompression || level > zlib.BestCompression {
+		s.ReplyWithMessage(StatusSyntaxError, "Invalid compression level.")
+		return
+	}
+
+	s.SetDeflateLevel(level)
+
+	s.ReplyWithMessage(StatusOK, fmt.Sprintf("MODE Z LEVEL set to %d.", level))
+}
+
 func init() {
 	CommandMap["MODE"] = &commandMODE{}
+	optsMap["MODE"] = optsMODE
+	featSlice = append(featSlice, "MODE Z")
 }
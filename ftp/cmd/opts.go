@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+)
+
+/*
+   OPTIONS (OPTS)
+
+      The OPTS (options) command allows a user-PI to specify the desired
+      behavior of a server-FTP process when another FTP command (the target
+      command) is later issued.  The exact behavior, and syntax, will vary
+      with the target command indicated, and will be specified with the
+      definition of that command.  Where no OPTS behavior is defined for a
+      particular command there are no options available for that command.
+
+      Request Syntax:
+         opts             = opts-cmd SP command-name
+                                [ SP command-options ] CRLF
+         opts-cmd         = "opts"
+         command-name     = <any FTP command which allows option setting>
+         command-options  = <format specified by individual FTP command>
+
+      Response Syntax:
+         opts-response    = opts-good / opts-bad
+         opts-good        = "200" SP response-message CRLF
+         opts-bad         = "451" SP response-message CRLF /
+                            "501" SP response-message CRLF
+         response-message = *TCHAR
+*/
+
+// optsMap holds the OPTS handlers for any command that supports options,
+// commands register themselves in their init
+var optsMap = map[string]func(Session, []string){}
+
+type commandOPTS struct{}
+
+func (c commandOPTS) RequireState() SessionState { return SessionStateAuth }
+
+func (c commandOPTS) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	fn, ok := optsMap[strings.ToUpper(params[0])]
+	if !ok {
+		s.ReplyWithMessage(StatusSyntaxError, "Option not understood.")
+		return nil
+	}
+
+	fn(s, params[1:])
+	return nil
+}
+
+func init() {
+	CommandMap["OPTS"] = &commandOPTS{}
+
+	optsMap["UTF8"] = func(s Session, params []string) {
+		if len(params) != 1 || strings.ToUpper(params[0]) != "ON" {
+			s.ReplyStatus(StatusSyntaxError)
+			return
+		}
+		s.ReplyWithMessage(StatusOK, "UTF8 set to on.")
+	}
+}
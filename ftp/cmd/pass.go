@@ -45,11 +45,18 @@ func (c commandPASS) Execute(ctx context.Context, s Session, params []string) er
 		return nil
 	}
 
-	conn := s.Control()
-	laddr := conn.LocalAddr()
-	raddr := conn.RemoteAddr()
+	laddr := s.Control().LocalAddr()
+	raddr := s.RemoteAddr()
+
+	// a bouncer has already done the ident lookup
+	var ipOK bool
+	if len(s.Ident()) > 0 {
+		ipOK = s.Auth().CheckIdent(s.Login(), s.Ident(), raddr)
+	} else {
+		ipOK = s.Auth().CheckIP(s.Login(), laddr, raddr)
+	}
 
-	if !s.Auth().CheckIP(s.Login(), laddr, raddr) {
+	if !ipOK {
 		s.SetLogin("")
 		s.ReplyStatus(StatusNotLoggedIn)
 		return nil
@@ -68,6 +75,12 @@ func (c commandPASS) Execute(ctx context.Context, s Session, params []string) er
 		return nil
 	}
 
+	if err := s.ReserveLogin(user); err != nil {
+		s.SetLogin("")
+		s.ReplyError(StatusNotLoggedIn, err)
+		return nil
+	}
+
 	s.ReplyWithArgs(StatusUserLoggedIn, fmt.Sprintf("Welcome back %s!", s.Login()))
 
 	go func() {
@@ -79,6 +92,9 @@ func (c commandPASS) Execute(ctx context.Context, s Session, params []string) er
 
 	s.SetState(SessionStateLoggedIn)
 
+	// the user may have their own idle timeout
+	s.SetIdleTimeout(s.MaxIdleTimeout())
+
 	return nil
 }
 
@@ -2,8 +2,9 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"net"
 )
 
 /*
@@ -16,11 +17,18 @@ import (
       host and port address this server is listening on.
 */
 
-type commandPASV struct{}
+type commandPASV struct {
+	// set for CPSV
+	tlsClient bool
+}
 
 func (c commandPASV) RequireState() SessionState { return SessionStateLoggedIn }
 
 func (c commandPASV) Execute(ctx context.Context, s Session, params []string) error {
+	if s.EPSVAll() {
+		s.ReplyWithMessage(StatusBadCommandSequence, "PASV not allowed after EPSV ALL.")
+		return nil
+	}
 
 	// check if we have an existing data conncetion, if so cancel it
 	if s.Data() != nil {
@@ -31,21 +39,35 @@ func (c commandPASV) Execute(ctx context.Context, s Session, params []string) er
 	}
 
 	// create new passive data connection
-	if err := s.NewPassiveDataConn(ctx); err != nil {
+	if err := s.NewPassiveDataConn(ctx, c.tlsClient || s.SSCN()); err != nil {
 		s.ReplyError(StatusCantOpenDataConnection, err)
 		return nil
 	}
 
-	s.ReplyWithArgs(StatusPassiveMode, c.toString(s.Data()))
+	addr, err := c.toString(s.Data())
+	if err != nil {
+		s.Data().Close()
+		s.ClearData()
+		s.ReplyError(StatusCantOpenDataConnection, err)
+		return nil
+	}
+
+	s.ReplyWithArgs(StatusPassiveMode, addr)
 	return nil
 }
 
-func (c commandPASV) toString(d DataConn) string {
+// toString formats the data connection in the h1,h2,h3,h4,p1,p2 format, PASV
+// can only describe IPv4 addresses so anything else needs to use EPSV
+func (c commandPASV) toString(d DataConn) (string, error) {
+	ip := net.ParseIP(d.Host()).To4()
+	if ip == nil {
+		return "", errors.New("PASV requires an IPv4 address, use EPSV")
+	}
+
 	p1 := d.Port() / 256
 	p2 := d.Port() - (p1 * 256)
 
-	parts := strings.Split(d.Host(), ".")
-	return fmt.Sprintf("(%s,%s,%s,%s,%d,%d)", parts[0], parts[1], parts[2], parts[3], p1, p2)
+	return fmt.Sprintf("(%d,%d,%d,%d,%d,%d)", ip[0], ip[1], ip[2], ip[3], p1, p2), nil
 }
 
 func init() {
@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 )
 
 /*
@@ -35,6 +39,17 @@ func (c commandPORT) Execute(ctx context.Context, s Session, params []string) er
 		return nil
 	}
 
+	if s.EPSVAll() {
+		s.ReplyWithMessage(StatusBadCommandSequence, "PORT not allowed after EPSV ALL.")
+		return nil
+	}
+
+	addr, err := c.parse(params[0])
+	if err != nil {
+		s.ReplyError(StatusSyntaxError, err)
+		return nil
+	}
+
 	// check if we have an existing data conncetion, if so cancel it
 	if s.Data() != nil {
 		if err := s.Data().Close(); err != nil {
@@ -43,8 +58,8 @@ func (c commandPORT) Execute(ctx context.Context, s Session, params []string) er
 		}
 	}
 
-	// create new passive data connection
-	if err := s.NewActiveDataConn(ctx, params[0]); err != nil {
+	// create new active data connection
+	if err := s.NewActiveDataConn(ctx, addr, s.SSCN()); err != nil {
 		s.ReplyError(StatusCantOpenDataConnection, err)
 		return nil
 	}
@@ -53,6 +68,29 @@ func (c commandPORT) Execute(ctx context.Context, s Session, params []string) er
 	return nil
 }
 
+// parse takes a h1,h2,h3,h4,p1,p2 string and returns it as a host:port
+// address
+func (c commandPORT) parse(param string) (string, error) {
+	parts := strings.Split(param, ",")
+	if len(parts) != 6 {
+		return "", errors.New("expected h1,h2,h3,h4,p1,p2")
+	}
+
+	var nums [6]int
+	for idx := range parts {
+		n, err := strconv.Atoi(parts[idx])
+		if err != nil || n < 0 || n > 255 {
+			return "", fmt.Errorf("bad value '%s'", parts[idx])
+		}
+		nums[idx] = n
+	}
+
+	host := fmt.Sprintf("%d.%d.%d.%d", nums[0], nums[1], nums[2], nums[3])
+	port := (nums[4] * 256) + nums[5]
+
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
 func init() {
 	CommandMap["PORT"] = &commandPORT{}
 }
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/goftpd/goftpd/vfs"
+)
+
+/*
+   PRE TRANSFER (PRET)
+
+      Sent by distributed aware clients before PASV or EPSV to announce
+      the transfer command that will follow, so the server can decide
+      where the data connection should be made. The command and its
+      arguments are given as they will be sent.
+
+         PRET <SP> <command> [<SP> <arguments>] <CRLF>
+
+      The transfer is checked up front, so a client without permission
+      fails here rather than after a passive port has been opened.
+*/
+
+type commandPRET struct{}
+
+func (c commandPRET) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandPRET) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	ftpCommand := strings.ToUpper(params[0])
+	params = params[1:]
+
+	var err error
+
+	switch ftpCommand {
+	case "RETR":
+		if len(params) == 0 {
+			s.ReplyStatus(StatusSyntaxError)
+			return nil
+		}
+
+		err = s.FS().CheckDownload(s.FS().Join(s.CWD(), params), user)
+
+	case "STOR", "APPE":
+		if len(params) == 0 {
+			s.ReplyStatus(StatusSyntaxError)
+			return nil
+		}
+
+		path := s.FS().Join(s.CWD(), params)
+
+		// checked the way the command will be, STOR after REST or RANG and
+		// APPE to an existing file resume it, anything else uploads
+		var resume bool
+		if ftpCommand == "STOR" {
+			_, end := s.Range()
+			resume = s.RestartPosition() > 0 || end > 0
+		} else {
+			_, sizeErr := s.FS().Size(path)
+			resume = sizeErr == nil
+		}
+
+		if resume {
+			err = s.FS().CheckResume(path, user)
+		} else {
+			err = s.FS().CheckUpload(path, user)
+		}
+
+	case "LIST", "NLST":
+		_, params = parseListParams(params)
+
+		path := s.FS().Join(s.CWD(), params)
+		if isGlob(filepath.Base(path)) {
+			path = filepath.Dir(path)
+		}
+
+		_, err = s.FS().Stat(path, user)
+
+	case "MLSD":
+		var finfo vfs.FileInfo
+		finfo, err = s.FS().Stat(s.FS().Join(s.CWD(), params), user)
+		if err == nil && !finfo.IsDir() {
+			err = errors.New("not a directory")
+		}
+
+	default:
+		s.ReplyStatus(StatusParameterNotImplemented)
+		return nil
+	}
+
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	s.SetPRET(ftpCommand, s.FS().Join(s.CWD(), params))
+
+	s.ReplyWithMessage(StatusOK, "OK, this server will be used for the upcoming transfer.")
+	return nil
+}
+
+func init() {
+	CommandMap["PRET"] = &commandPRET{}
+	featSlice = append(featSlice, "PRET")
+}
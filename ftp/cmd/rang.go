@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+/*
+   RANGE (RANG)
+
+      The RANG command is used to specify a byte range for the next
+      command. Both the start and end points are inclusive. RETR sends
+      only the range and STOR writes over it, leaving the rest of the
+      file as it is. RANG and REST each reset the other.
+
+         RANG <SP> <start-point> <SP> <end-point> <CRLF>
+
+      A RANG of 1 0 resets the range so that the whole file is used. If
+      the start point is greater than the end point a 501 reply is
+      returned, otherwise the server responds with:
+
+         350 Restarting at <start-point>. Ending byte <end-point>.
+*/
+
+type commandRANG struct{}
+
+func (c commandRANG) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandRANG) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 2 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	start, err := strconv.ParseInt(params[0], 10, 64)
+	if err != nil || start < 0 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	end, err := strconv.ParseInt(params[1], 10, 64)
+	if err != nil || end < 0 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	s.SetRestartPosition(0)
+
+	if start == 1 && end == 0 {
+		s.SetRange(0, 0)
+		s.ReplyWithMessage(StatusPendingMoreInfo, "Restarting at 0. Ending byte EOF.")
+		return nil
+	}
+
+	if start > end {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	// store end as exclusive
+	s.SetRange(start, end+1)
+
+	s.ReplyWithMessage(StatusPendingMoreInfo, fmt.Sprintf("Restarting at %d. Ending byte %d.", start, end))
+	return nil
+}
+
+func init() {
+	CommandMap["RANG"] = &commandRANG{}
+	featSlice = append(featSlice, "RANG STREAM")
+}
@@ -32,7 +32,9 @@ func (c commandREST) Execute(ctx context.Context, s Session, params []string) er
 		return nil
 	}
 
+	// a transfer either restarts or uses a range, not both
 	s.SetRestartPosition(position)
+	s.SetRange(0, 0)
 
 	s.ReplyStatus(StatusPendingMoreInfo)
 	return nil
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/goftpd/goftpd/acl"
 )
@@ -41,11 +42,36 @@ func (c commandRETR) Execute(ctx context.Context, s Session, params []string) er
 		return errors.New("no user found")
 	}
 
+	release, err := s.ReserveTransfer(user, false)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+	defer release()
+
 	reader, size, err := s.FS().DownloadFile(path, user)
 	if err != nil {
 		s.ReplyError(StatusActionNotOK, err)
 		return nil
 	}
+	defer reader.Close()
+
+	// RANG sends part of the file, REST resets it so only one is set
+	start, end := s.Range()
+	defer s.SetRange(0, 0)
+
+	if end > 0 {
+		if start >= size {
+			s.ReplyWithMessage(StatusActionNotOK, "Range start is beyond the end of the file.")
+			return nil
+		}
+
+		if end > size {
+			end = size
+		}
+
+		size = end - start
+	}
 
 	var returnCredits bool
 	if user.Ratio > 0 {
@@ -91,25 +117,56 @@ func (c commandRETR) Execute(ctx context.Context, s Session, params []string) er
 	defer s.SetRestartPosition(0)
 
 	// seek reader
-	if s.RestartPosition() > 0 {
-		if _, err := reader.Seek(int64(s.RestartPosition()), io.SeekStart); err != nil {
+	offset := int64(s.RestartPosition())
+	if end > 0 {
+		offset = start
+	}
+
+	if offset > 0 {
+		if _, err := reader.Seek(offset, io.SeekStart); err != nil {
 			s.ReplyError(StatusActionNotOK, err)
 			returnCredits = true
 			return nil
 		}
 	}
 
-	buf := s.FS().GetBuffer()
-	defer s.FS().PutBuffer(buf)
+	var src io.Reader = reader
+	if end > 0 {
+		src = io.LimitReader(reader, end-start)
+	}
+
+	// send straight from the file when the data connection can, otherwise
+	// copy it through a buffer
+	started := time.Now()
+
+	var n int64
+	var sent bool
+	if zc, ok := s.Data().(ZeroCopier); ok {
+		n, sent, err = zc.ZeroCopy(src)
+	}
+
+	if !sent {
+		buf := s.FS().GetBuffer()
+		defer s.FS().PutBuffer(buf)
+
+		n, err = io.CopyBuffer(s.Data(), src, *buf)
+	}
+
+	// nothing was written for an empty file, an empty write still lets a
+	// wrapped connection send what it has to, e.g. an empty MODE Z stream
+	if err == nil && n == 0 {
+		_, err = s.Data().Write(nil)
+	}
 
-	n, err := io.CopyBuffer(s.Data(), reader, *buf)
 	if err != nil {
+		s.RecordTransfer(newTransferStats(s, "RETR", path, n, started, false))
 		s.ReplyError(StatusActionNotOK, err)
 		returnCredits = true
 		return nil
 	}
 
 	if err := s.Data().Close(); err != nil {
+		s.RecordTransfer(newTransferStats(s, "RETR", path, n, started, false))
 		s.ReplyError(StatusActionNotOK, err)
 		// TODO
 		// hard to say if we should return credits here
@@ -117,10 +174,13 @@ func (c commandRETR) Execute(ctx context.Context, s Session, params []string) er
 		return nil
 	}
 
+	s.RecordTransfer(newTransferStats(s, "RETR", path, n, started, true))
+
 	s.ReplyWithMessage(StatusDataClosedOK, fmt.Sprintf("OK, sent %d bytes.", n))
 	return nil
 }
 
 func init() {
 	CommandMap["RETR"] = &commandRETR{}
+	TransferCommands["RETR"] = true
 }
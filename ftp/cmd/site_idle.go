@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+/*
+
+   SITE IDLE [<seconds>]
+
+      Shows the session's idle timeout, or lowers it to the number of
+      seconds given. It can't be raised past the user's own idle
+      timeout, or the server's when they don't have one.
+*/
+
+type commandSITEIDLE struct{}
+
+func (c commandSITEIDLE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandSITEIDLE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) > 1 {
+		s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE IDLE [<seconds>]")
+		return nil
+	}
+
+	max := s.MaxIdleTimeout()
+
+	if len(params) == 0 {
+		s.ReplyWithMessage(StatusOK, fmt.Sprintf("Idle timeout: %d seconds (max %d).", s.IdleTimeout(), max))
+		return nil
+	}
+
+	idle, err := strconv.Atoi(params[0])
+	if err != nil || idle < 1 {
+		s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE IDLE [<seconds>]")
+		return nil
+	}
+
+	// can only be lowered, 0 is no limit
+	if max > 0 && idle > max {
+		s.ReplyWithMessage(StatusActionNotOK, fmt.Sprintf("Idle timeout can't be more than %d seconds.", max))
+		return nil
+	}
+
+	s.SetIdleTimeout(idle)
+
+	s.ReplyWithMessage(StatusOK, fmt.Sprintf("Idle timeout set to %d seconds.", idle))
+	return nil
+}
+
+func init() {
+	SiteCommandMap["IDLE"] = &commandSITEIDLE{}
+}
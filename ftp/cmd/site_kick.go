@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+/*
+
+   SITE KICK <user>
+
+      Disconnects every session of the user, other than the one
+      sending the command, aborting any transfers they have running.
+
+   SITE KILL <session-id>
+
+      Disconnects a single session, the id is the one shown by
+      SITE SWHO.
+*/
+
+type commandSITEKICK struct{}
+
+func (c commandSITEKICK) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandSITEKICK) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 {
+		s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE KICK <user>")
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	n := s.KickUser(params[0], fmt.Sprintf("Kicked by %s.", user.Name))
+	if n == 0 {
+		s.ReplyWithMessage(StatusActionNotOK, fmt.Sprintf("%s is not online.", params[0]))
+		return nil
+	}
+
+	s.ReplyWithMessage(StatusOK, fmt.Sprintf("Kicked %d session(s) of %s.", n, params[0]))
+
+	return nil
+}
+
+type commandSITEKILL struct{}
+
+func (c commandSITEKILL) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandSITEKILL) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 {
+		s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE KILL <session-id>")
+		return nil
+	}
+
+	id, err := strconv.ParseUint(params[0], 10, 64)
+	if err != nil {
+		s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE KILL <session-id>")
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	if err := s.KillSession(id, fmt.Sprintf("Killed by %s.", user.Name)); err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	s.ReplyWithMessage(StatusOK, fmt.Sprintf("Killed session %d.", id))
+
+	return nil
+}
+
+func init() {
+	SiteCommandMap["KICK"] = &commandSITEKICK{}
+	SiteCommandMap["KILL"] = &commandSITEKILL{}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+/*
+
+   SITE WHO
+
+      Lists the users logged in and what each is doing, with the file
+      and speed of any transfer they have running.
+
+   SITE SWHO
+
+      The staff view of SITE WHO, every session including those not yet
+      logged in, with its id for SITE KILL, address and working
+      directory.
+*/
+
+type commandSITEWHO struct{}
+
+func (c commandSITEWHO) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandSITEWHO) Execute(ctx context.Context, s Session, params []string) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%-12s %-12s %-8s %s\n", "User", "Group", "Idle", "Action")
+
+	var count int
+
+	for _, info := range s.Sessions() {
+		if len(info.User) == 0 {
+			continue
+		}
+
+		action := orDash(info.Command)
+		if len(info.Direction) > 0 {
+			action = describeTransfer(info, false)
+		}
+
+		fmt.Fprintf(&b, " %-12s %-12s %-8s %s\n", info.User, orDash(info.Group), formatDuration(info.Idle), action)
+
+		count++
+	}
+
+	fmt.Fprintf(&b, " %d user(s) online.", count)
+
+	s.ReplyWithMessage(StatusOK, b.String())
+
+	return nil
+}
+
+type commandSITESWHO struct{}
+
+func (c commandSITESWHO) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandSITESWHO) Execute(ctx context.Context, s Session, params []string) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%-6s %-12s %-12s %-28s %-8s %-8s %s\n", "ID", "User", "Group", "Address", "Online", "Idle", "Action")
+
+	sessions := s.Sessions()
+
+	for _, info := range sessions {
+		addr := info.RemoteAddr
+		if len(info.Ident) > 0 {
+			addr = info.Ident + "@" + addr
+		}
+
+		action := orDash(info.Command)
+		if len(info.Direction) > 0 {
+			action = describeTransfer(info, true)
+		}
+
+		if len(info.CWD) > 0 {
+			action += " in " + info.CWD
+		}
+
+		fmt.Fprintf(
+			&b,
+			" %-6d %-12s %-12s %-28s %-8s %-8s %s\n",
+			info.ID,
+			orDash(info.User),
+			orDash(info.Group),
+			addr,
+			formatDuration(time.Since(info.Connected)),
+			formatDuration(info.Idle),
+			action,
+		)
+	}
+
+	fmt.Fprintf(&b, " %d session(s) connected.", len(sessions))
+
+	s.ReplyWithMessage(StatusOK, b.String())
+
+	return nil
+}
+
+// describeTransfer shows what a transfer is doing, the full path is only
+// shown to staff
+func describeTransfer(info SessionInfo, full bool) string {
+	p := info.Path
+	if !full {
+		p = path.Base(p)
+	}
+
+	switch info.Direction {
+	case "download":
+		return fmt.Sprintf("DL %s %.0fKB/s", p, info.Speed/1024)
+	case "upload":
+		return fmt.Sprintf("UL %s %.0fKB/s", p, info.Speed/1024)
+	default:
+		return info.Command
+	}
+}
+
+// formatDuration drops the fractions of a second
+func formatDuration(d time.Duration) string {
+	return d.Truncate(time.Second).String()
+}
+
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	SiteCommandMap["WHO"] = &commandSITEWHO{}
+	SiteCommandMap["SWHO"] = &commandSITESWHO{}
+}
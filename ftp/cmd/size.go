@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+/*
+   FILE SIZE (SIZE)
+
+      The FTP command, SIZE OF FILE (SIZE), is used to obtain the transfer
+      size of a file from the server-FTP process.  This is the exact number
+      of octets (8 bit bytes) that would be transmitted over the data
+      connection should that file be transmitted.  This value will change
+      depending on the current STRUcture, MODE, and TYPE of the data
+      connection or of a data connection that would be created were one
+      created now.  Thus, the result of the SIZE command is dependent on
+      the currently established STRU, MODE, and TYPE parameters.
+
+      The SIZE command returns how many octets would be transferred if the
+      file were to be transferred using the current transfer structure,
+      mode, and type.  This command is normally used in conjunction with
+      the RESTART (REST) command when STORing a file to a remote server in
+      STREAM mode, to determine the restart point.
+*/
+
+type commandSIZE struct{}
+
+func (c commandSIZE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandSIZE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	finfo, err := s.FS().Stat(path, user)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	if finfo.IsDir() {
+		s.ReplyWithMessage(StatusActionNotOK, "Not a plain file.")
+		return nil
+	}
+
+	size := finfo.Size()
+
+	// in TYPE A line endings are translated so we have to count them, the
+	// count is kept in the shadow so this only reads the file once
+	if !s.BinaryMode() {
+		size, err = s.FS().ASCIISize(path, user)
+		if err != nil {
+			s.ReplyError(StatusActionNotOK, err)
+			return nil
+		}
+	}
+
+	s.ReplyWithMessage(StatusFileStatus, fmt.Sprintf("%d", size))
+	return nil
+}
+
+func init() {
+	CommandMap["SIZE"] = &commandSIZE{}
+	featSlice = append(featSlice, "SIZE")
+}
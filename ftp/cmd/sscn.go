@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+)
+
+/*
+   SET SECURED CLIENT NEGOTIATION (SSCN)
+
+      Used for secure server to server transfers (FXP). Normally the FTP
+      client is the TLS client on the data connection, but when both ends
+      are servers one of them has to take that role. SSCN ON makes this
+      server act as the TLS client on subsequent data connections, SSCN
+      OFF goes back to acting as the TLS server. With no argument the
+      current method is returned.
+
+         SSCN [ON|OFF]
+         200 SSCN:CLIENT METHOD
+         200 SSCN:SERVER METHOD
+*/
+
+type commandSSCN struct{}
+
+func (c commandSSCN) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandSSCN) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) > 1 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	if len(params) == 1 {
+		switch strings.ToUpper(params[0]) {
+		case "ON":
+			s.SetSSCN(true)
+		case "OFF":
+			s.SetSSCN(false)
+		default:
+			s.ReplyStatus(StatusSyntaxError)
+			return nil
+		}
+	}
+
+	if s.SSCN() {
+		s.ReplyWithMessage(StatusOK, "SSCN:CLIENT METHOD")
+	} else {
+		s.ReplyWithMessage(StatusOK, "SSCN:SERVER METHOD")
+	}
+
+	return nil
+}
+
+func init() {
+	CommandMap["SSCN"] = &commandSSCN{}
+	featSlice = append(featSlice, "SSCN")
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
 /*
@@ -29,7 +31,7 @@ import (
 
 const statBaseMessage string = `FTP server status:
 Logged in as %s
-TYPE: %s, FORM: Nonprint; STRUcture: File; transfer MODE: Stream; Protection: %s
+TYPE: %s, FORM: Nonprint; STRUcture: File; transfer MODE: %s; Protection: %s
 %s
 `
 
@@ -47,32 +49,19 @@ func (c commandSTAT) Execute(ctx context.Context, s Session, params []string) er
 		return errors.New("no user found")
 	}
 
-	var options, path string
+	opts, params := parseListParams(params)
 
-	// check if we have options and set the path eitherway
-	if len(params[0]) > 0 && params[0][0] == '-' {
-		options = params[0]
-		if len(params) > 1 {
-			params = params[1:]
-		} else {
-			params = []string{}
-		}
-	}
+	// STAT is always detailed
+	opts.long = true
 
-	path = s.FS().Join(s.CWD(), params)
+	path := s.FS().Join(s.CWD(), params)
 
-	// get file list and parse with any options
-	finfo, err := s.FS().ListDir(path, user)
+	b, err := listing(s, user, path, strings.Join(params, " "), opts)
 	if err != nil {
 		s.ReplyError(StatusActionAbortedError, err)
 		return nil
 	}
 
-	if len(options) > 0 {
-		// handle options
-		finfo.SortByName()
-	}
-
 	s.ReplyWithMessage(
 		StatusFileStatus,
 
@@ -82,18 +71,39 @@ func (c commandSTAT) Execute(ctx context.Context, s Session, params []string) er
 		fmt.Sprintf(
 			"Status of \"-l\":\n%s",
 			// path,
-			finfo.Detailed(),
+			b,
 		),
 	)
 	return nil
 }
 
 func (c commandSTAT) NoParams(s Session) error {
-	data := s.Data()
-
-	// check if we have an existing data conncetion, if so cancel it
+	// report on a running transfer, the data connection belongs to it
+	// until it finishes so don't touch the session's
 	dataMessage := "No data connection"
-	if data != nil {
+	if t := s.Transfer(); t != nil {
+		data := t.Data()
+		bytes := data.BytesWritten() + data.BytesRead()
+		wire := data.WireBytesWritten() + data.WireBytesRead()
+
+		elapsed := time.Since(t.Started())
+
+		var speed float64
+		if elapsed > 0 {
+			speed = float64(bytes) / 1024 / elapsed.Seconds()
+		}
+
+		dataMessage = fmt.Sprintf(
+			"%s Data Connection. %s in progress, %d bytes (%d on the wire) in %s (%.2f KiB/s).",
+			data.Kind(),
+			t.Command(),
+			bytes,
+			wire,
+			elapsed.Truncate(time.Second),
+			speed,
+		)
+
+	} else if data := s.Data(); data != nil {
 		dataMessage = fmt.Sprintf(
 			"%s Data Connection. Written %d bytes Read %d bytes.",
 			data.Kind(),
@@ -117,7 +127,7 @@ func (c commandSTAT) NoParams(s Session) error {
 		protection = "Protected"
 	}
 
-	msg := fmt.Sprintf(statBaseMessage, user.Name, dataType, protection, dataMessage)
+	msg := fmt.Sprintf(statBaseMessage, user.Name, dataType, s.TransferMode(), protection, dataMessage)
 
 	s.ReplyWithMessage(StatusSystemStatus, msg)
 	return nil
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/goftpd/goftpd/acl"
 )
@@ -43,7 +44,32 @@ func (c commandSTOR) Execute(ctx context.Context, s Session, params []string) er
 		return errors.New("no user found")
 	}
 
-	writer, err := s.FS().UploadFile(path, user)
+	// reset seek
+	defer s.SetRestartPosition(0)
+
+	// RANG writes over part of the file, REST resets it so only one is set
+	start, end := s.Range()
+	defer s.SetRange(0, 0)
+
+	release, err := s.ReserveTransfer(user, true)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+	defer release()
+
+	// REST before STOR resumes the upload from the restart position
+	var writer io.WriteCloser
+
+	offset := int64(s.RestartPosition())
+	switch {
+	case end > 0:
+		writer, err = s.FS().WriteFileAt(path, start, user)
+	case offset > 0:
+		writer, err = s.FS().ResumeUploadFile(path, offset, user)
+	default:
+		writer, err = s.FS().UploadFile(path, user)
+	}
 	if err != nil {
 		s.ReplyError(StatusActionNotOK, err)
 		return nil
@@ -67,20 +93,26 @@ func (c commandSTOR) Execute(ctx context.Context, s Session, params []string) er
 	buf := s.FS().GetBuffer()
 	defer s.FS().PutBuffer(buf)
 
-	n, err := io.CopyBuffer(writer, s.Data(), *buf)
-	if err != nil {
-		// delete the file
-		if err := s.FS().DeleteFile(path, acl.SuperUser); err != nil {
-			return err
-		}
+	started := time.Now()
 
-		s.ReplyError(StatusActionNotOK, err)
-		return nil
+	// anything past the end of the range is ignored
+	var src io.Reader = s.Data()
+	if end > 0 {
+		src = io.LimitReader(src, end-start)
 	}
 
+	n, copyErr := io.CopyBuffer(writer, src, *buf)
+
 	s.Data().Close()
 
-	if n == 0 {
+	// close now so the shadow is up to date before we reply or delete
+	if err := writer.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	if n == 0 && offset == 0 && end == 0 {
+		s.RecordTransfer(newTransferStats(s, "STOR", path, n, started, false))
+
 		if err := s.FS().DeleteFile(path, acl.SuperUser); err != nil {
 			return err
 		}
@@ -91,19 +123,29 @@ func (c commandSTOR) Execute(ctx context.Context, s Session, params []string) er
 	// TODO
 	// do we want to store for ratio 0?
 	if n > 1024 {
-		n = n / 1024
+		kb := n / 1024
 		go func() {
 			s.Auth().UpdateUser(user.Name, func(u *acl.User) error {
-				u.Credits += n * int64(u.Ratio)
+				u.Credits += kb * int64(u.Ratio)
 				return nil
 			})
 		}()
 	}
 
+	// keep what we received so the upload can be resumed with REST
+	if copyErr != nil {
+		s.RecordTransfer(newTransferStats(s, "STOR", path, n, started, false))
+		s.ReplyError(StatusActionNotOK, copyErr)
+		return nil
+	}
+
+	s.RecordTransfer(newTransferStats(s, "STOR", path, n, started, true))
+
 	s.ReplyWithMessage(StatusDataClosedOK, fmt.Sprintf("OK, received %d bytes.", n))
 	return nil
 }
 
 func init() {
 	CommandMap["STOR"] = &commandSTOR{}
+	TransferCommands["STOR"] = true
 }
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/goftpd/goftpd/vfs"
+)
+
+/*
+   XCRC, XMD5, XSHA1, XSHA256
+
+      Legacy hashing commands supported by a number of clients. They take
+      a pathname and an optional start and end point, to use the optional
+      points with a pathname containing spaces the pathname must be
+      quoted:
+
+         XCRC <SP> <pathname> [<SP> <start-point> [<SP> <end-point>]]
+
+      The hash is returned as hex in a 250 reply.
+*/
+
+type commandXHASH struct {
+	algorithm string
+}
+
+func (c commandXHASH) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandXHASH) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		s.ReplyStatus(StatusSyntaxError)
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	params, start, end, err := c.parse(params)
+	if err != nil {
+		s.ReplyError(StatusSyntaxError, err)
+		return nil
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	sum, err := s.FS().HashFile(path, c.algorithm, start, end, user)
+	if err != nil {
+		s.ReplyError(StatusActionNotOK, err)
+		return nil
+	}
+
+	s.ReplyWithMessage(StatusFileActionOK, strings.ToUpper(hex.EncodeToString(sum)))
+	return nil
+}
+
+// parse extracts the optional start and end points from a quoted pathname,
+// an unquoted pathname is used as is
+func (c commandXHASH) parse(params []string) ([]string, int64, int64, error) {
+	line := strings.Join(params, " ")
+
+	if len(line) == 0 || line[0] != '"' {
+		return params, 0, 0, nil
+	}
+
+	idx := strings.Index(line[1:], `"`)
+	if idx < 0 {
+		return nil, 0, 0, errors.New("missing closing quote")
+	}
+
+	path := line[1 : idx+1]
+	points := strings.Fields(line[idx+2:])
+
+	if len(points) > 2 {
+		return nil, 0, 0, errors.New("too many arguments")
+	}
+
+	var start, end int64
+	var err error
+
+	if len(points) > 0 {
+		start, err = strconv.ParseInt(points[0], 10, 64)
+		if err != nil || start < 0 {
+			return nil, 0, 0, errors.New("bad start point")
+		}
+	}
+
+	if len(points) > 1 {
+		end, err = strconv.ParseInt(points[1], 10, 64)
+		if err != nil || end < start {
+			return nil, 0, 0, errors.New("bad end point")
+		}
+	}
+
+	return []string{path}, start, end, nil
+}
+
+func init() {
+	CommandMap["XCRC"] = &commandXHASH{vfs.HashCRC32}
+	CommandMap["XMD5"] = &commandXHASH{vfs.HashMD5}
+	CommandMap["XSHA1"] = &commandXHASH{vfs.HashSHA1}
+	CommandMap["XSHA256"] = &commandXHASH{vfs.HashSHA256}
+
+	featSlice = append(featSlice, "XCRC", "XMD5", "XSHA1", "XSHA256")
+}
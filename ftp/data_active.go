@@ -5,51 +5,81 @@ import (
 	"crypto/tls"
 	"net"
 	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 type activeDataConn struct {
-	ctx context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
 
+	// set when the data connection is protected, tlsClient swaps our role
+	// in the handshake for SSCN
 	tlsConfig *tls.Config
+	tlsClient bool
 
 	conn net.Conn
 
+	// how long to wait for the connection to be made, and for a Read or
+	// Write before giving up on a stalled transfer
+	connectTimeout time.Duration
+	stallTimeout   time.Duration
+
 	host string
 	port int64
 
-	written int
-	read    int
+	written int64
+	read    int64
+
+	closed bool
 
 	sync.Mutex
 }
 
-func (s *Server) newActiveDataConn(ctx context.Context, param string, dataProtected bool) (*activeDataConn, error) {
-	parts := strings.Split(param, ",")
-
-	portOne, err := strconv.Atoi(parts[4])
+// newActiveDataConn takes an address in the form of host:port, where host
+// can be either an IPv4 or IPv6 address, and returns an activeDataConn that
+// will lazily connect on the first Read or Write. If dataProtected is set the
+// connection is upgraded to TLS, as the TLS client if tlsClient is set
+func (s *Server) newActiveDataConn(ctx context.Context, addr string, dataProtected, tlsClient bool) (*activeDataConn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
 	if err != nil {
 		return nil, err
 	}
 
-	portTwo, err := strconv.Atoi(parts[5])
+	if net.ParseIP(host) == nil {
+		return nil, errors.Errorf("invalid host '%s'", host)
+	}
+
+	port, err := strconv.Atoi(portStr)
 	if err != nil {
 		return nil, err
 	}
 
-	port := int64((portOne * 256) + portTwo)
-	host := parts[0] + "." + parts[1] + "." + parts[2] + "." + parts[3]
+	if port < 1 || port > 65535 {
+		return nil, errors.Errorf("invalid port '%d'", port)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
 
 	d := activeDataConn{
-		ctx:  ctx,
-		host: host,
-		port: port,
+		ctx:    ctx,
+		cancel: cancel,
+		host:   host,
+		port:   int64(port),
+
+		connectTimeout: time.Duration(s.options().DataConnectTimeout) * time.Second,
+		stallTimeout:   time.Duration(s.options().StallTimeout) * time.Second,
 	}
 
 	if dataProtected {
 		d.tlsConfig = s.TLSConfig()
+		d.tlsClient = tlsClient
+		if tlsClient {
+			d.tlsConfig = s.tlsClientConfig()
+		}
 	}
 
 	return &d, nil
@@ -64,13 +94,20 @@ func (d *activeDataConn) connect() error {
 		return nil
 	}
 
+	if d.closed {
+		return errors.New("data connection closed")
+	}
+
 	addr := net.JoinHostPort(d.host, strconv.Itoa(int(d.port)))
 
 	dialer := net.Dialer{
-		Timeout: time.Second * 60,
 		// TODO: LocalAddr we probably want to be able to configure this
 	}
 
+	if d.connectTimeout > 0 {
+		dialer.Timeout = d.connectTimeout
+	}
+
 	var err error
 
 	d.conn, err = dialer.DialContext(d.ctx, "tcp", addr)
@@ -85,18 +122,33 @@ func (d *activeDataConn) connect() error {
 			connection with a connect() call or which side reacts to the
 			connection via the accept() call; the FTP client, as defined in
 			[RFC-959], is always the TLS client, as defined in [RFC-2246].
+
+			The exception being SSCN, used for FXP, where one of the servers
+			has to act as the client.
 		*/
-		d.conn = tls.Server(d.conn, d.tlsConfig)
+		if d.tlsClient {
+			d.conn = tls.Client(d.conn, d.tlsConfig)
+		} else {
+			d.conn = tls.Server(d.conn, d.tlsConfig)
+		}
 	}
 
 	return nil
 }
 
-// Close implements the io.Closer
+// Close implements the io.Closer. Cancelling first means we don't wait
+// on a pending dial and a blocked Read or Write is interrupted
 func (d *activeDataConn) Close() error {
+	d.cancel()
+
 	d.Lock()
 	defer d.Unlock()
 
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+
 	if d.conn == nil {
 		return nil
 	}
@@ -114,8 +166,14 @@ func (d *activeDataConn) Read(p []byte) (int, error) {
 		return 0, err
 	}
 
+	if d.stallTimeout > 0 {
+		if err := d.conn.SetReadDeadline(time.Now().Add(d.stallTimeout)); err != nil {
+			return 0, err
+		}
+	}
+
 	n, err := d.conn.Read(p)
-	d.read += n
+	atomic.AddInt64(&d.read, int64(n))
 
 	return n, err
 }
@@ -130,14 +188,27 @@ func (d *activeDataConn) Write(p []byte) (int, error) {
 		return 0, err
 	}
 
+	if d.stallTimeout > 0 {
+		if err := d.conn.SetWriteDeadline(time.Now().Add(d.stallTimeout)); err != nil {
+			return 0, err
+		}
+	}
+
 	n, err := d.conn.Write(p)
-	d.written += n
+	atomic.AddInt64(&d.written, int64(n))
 
 	return n, err
 }
 
-func (d *activeDataConn) Host() string      { return d.host }
-func (d *activeDataConn) Port() int         { return int(d.port) }
-func (d *activeDataConn) BytesRead() int    { return d.read }
-func (d *activeDataConn) BytesWritten() int { return d.written }
-func (d *activeDataConn) Kind() string      { return "Active" }
+// peerIP is the address we connect to, known before we do
+func (d *activeDataConn) peerIP() (net.IP, error) {
+	return net.ParseIP(d.host), nil
+}
+
+func (d *activeDataConn) Host() string          { return d.host }
+func (d *activeDataConn) Port() int             { return int(d.port) }
+func (d *activeDataConn) BytesRead() int        { return int(atomic.LoadInt64(&d.read)) }
+func (d *activeDataConn) BytesWritten() int     { return int(atomic.LoadInt64(&d.written)) }
+func (d *activeDataConn) WireBytesRead() int    { return d.BytesRead() }
+func (d *activeDataConn) WireBytesWritten() int { return d.BytesWritten() }
+func (d *activeDataConn) Kind() string          { return "Active" }
@@ -0,0 +1,142 @@
+package ftp
+
+import (
+	"bufio"
+	"io"
+	"sync/atomic"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// asciiDataConn wraps a cmd.DataConn for TYPE A. Files are stored with LF line
+// endings, on the wire they are CRLF, so a bare LF written gets a CR added and
+// a CRLF read becomes a LF. BytesRead and BytesWritten count the file bytes
+type asciiDataConn struct {
+	cmd.DataConn
+
+	reader *bufio.Reader
+
+	// cr is set when the last byte written was a CR, so a following
+	// LF is already part of a CRLF
+	cr   bool
+	wbuf []byte
+
+	written int64
+	read    int64
+}
+
+// newASCIIDataConn returns an asciiDataConn, cr should be set if the first
+// LF written shouldn't be given a CR, i.e. a REST that lands inside a CRLF
+func newASCIIDataConn(d cmd.DataConn, cr bool) *asciiDataConn {
+	return &asciiDataConn{
+		DataConn: d,
+		cr:       cr,
+	}
+}
+
+// Read implements the io.Reader interface
+func (d *asciiDataConn) Read(p []byte) (int, error) {
+	if d.reader == nil {
+		d.reader = bufio.NewReaderSize(d.DataConn, 32*1024)
+	}
+
+	var n int
+	for n < len(p) {
+		// don't block on the connection if we have something to return
+		if n > 0 && d.reader.Buffered() == 0 {
+			break
+		}
+
+		b, err := d.reader.ReadByte()
+		if err != nil {
+			atomic.AddInt64(&d.read, int64(n))
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+
+		if b == '\r' {
+			// we need to see the next byte, come back to it rather
+			// than block with bytes to return
+			if n > 0 && d.reader.Buffered() == 0 {
+				d.reader.UnreadByte()
+				break
+			}
+
+			if next, err := d.reader.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+		}
+
+		p[n] = b
+		n++
+	}
+
+	atomic.AddInt64(&d.read, int64(n))
+
+	return n, nil
+}
+
+// Write implements the io.Writer interface
+func (d *asciiDataConn) Write(p []byte) (int, error) {
+	d.wbuf = d.wbuf[:0]
+
+	for _, b := range p {
+		if b == '\n' && !d.cr {
+			d.wbuf = append(d.wbuf, '\r')
+		}
+		d.wbuf = append(d.wbuf, b)
+		d.cr = b == '\r'
+	}
+
+	if _, err := d.DataConn.Write(d.wbuf); err != nil {
+		return 0, err
+	}
+
+	atomic.AddInt64(&d.written, int64(len(p)))
+
+	return len(p), nil
+}
+
+func (d *asciiDataConn) BytesRead() int    { return int(atomic.LoadInt64(&d.read)) }
+func (d *asciiDataConn) BytesWritten() int { return int(atomic.LoadInt64(&d.written)) }
+
+// scanASCII reads r, a file as stored, until limit bytes of its TYPE A
+// representation have been seen, or EOF if limit is negative. It returns
+// the file offset and the number of TYPE A bytes. If limit lands between
+// the CR and LF of a translated line ending, cr is set and the offset is
+// that of the LF
+func scanASCII(r io.Reader, limit int64, buf []byte) (offset, ascii int64, cr bool, err error) {
+	var prev byte
+
+	for {
+		n, err := r.Read(buf)
+
+		for _, b := range buf[:n] {
+			width := int64(1)
+			if b == '\n' && prev != '\r' {
+				width = 2
+			}
+
+			if limit >= 0 && ascii+width > limit {
+				if ascii+1 == limit {
+					return offset, limit, true, nil
+				}
+				return offset, ascii, false, nil
+			}
+
+			ascii += width
+			offset++
+			prev = b
+		}
+
+		if err == io.EOF {
+			return offset, ascii, false, nil
+		}
+
+		if err != nil {
+			return 0, 0, false, err
+		}
+	}
+}
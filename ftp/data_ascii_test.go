@@ -0,0 +1,183 @@
+package ftp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// chunkDataConn is a bufferDataConn whose reads return one chunk at a time,
+// as a connection would return what has arrived so far
+type chunkDataConn struct {
+	bufferDataConn
+	chunks [][]byte
+}
+
+func (d *chunkDataConn) Read(p []byte) (int, error) {
+	if len(d.chunks) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, d.chunks[0])
+	if d.chunks[0] = d.chunks[0][n:]; len(d.chunks[0]) == 0 {
+		d.chunks = d.chunks[1:]
+	}
+
+	return n, nil
+}
+
+func TestASCIIWrite(t *testing.T) {
+	var tests = []struct {
+		writes   []string
+		cr       bool
+		expected string
+	}{
+		{[]string{"a\nb\n"}, false, "a\r\nb\r\n"},
+		{[]string{"a\r\nb"}, false, "a\r\nb"},
+		{[]string{"a\rb"}, false, "a\rb"},
+		{[]string{"a\r", "\nb\n"}, false, "a\r\nb\r\n"},
+		{[]string{"\nb"}, true, "\nb"},
+		{[]string{""}, false, ""},
+	}
+
+	for idx, tt := range tests {
+		var conn bufferDataConn
+
+		d := newASCIIDataConn(&conn, tt.cr)
+
+		var n int
+		for _, w := range tt.writes {
+			if _, err := d.Write([]byte(w)); err != nil {
+				t.Fatalf("%d: unexpected error writing: %s", idx, err)
+			}
+			n += len(w)
+		}
+
+		if got := conn.String(); got != tt.expected {
+			t.Errorf("%d: expected %q got %q", idx, tt.expected, got)
+		}
+
+		if d.BytesWritten() != n {
+			t.Errorf("%d: expected %d file bytes written got %d", idx, n, d.BytesWritten())
+		}
+	}
+}
+
+func TestASCIIRead(t *testing.T) {
+	var tests = []struct {
+		chunks   []string
+		expected string
+	}{
+		{[]string{"a\r\nb\r\n"}, "a\nb\n"},
+		{[]string{"a\nb"}, "a\nb"},
+		// a CR on its own is kept
+		{[]string{"a\rb"}, "a\rb"},
+		{[]string{"a\r"}, "a\r"},
+		// the LF of a CRLF arrives in the next read
+		{[]string{"a\r", "\nb"}, "a\nb"},
+		{[]string{"a\r", "b"}, "a\rb"},
+		{[]string{"\r", "\n", "\r", "\n"}, "\n\n"},
+	}
+
+	for idx, tt := range tests {
+		conn := chunkDataConn{}
+		for _, c := range tt.chunks {
+			conn.chunks = append(conn.chunks, []byte(c))
+		}
+
+		d := newASCIIDataConn(&conn, false)
+
+		got, err := ioutil.ReadAll(d)
+		if err != nil {
+			t.Fatalf("%d: unexpected error reading: %s", idx, err)
+		}
+
+		if string(got) != tt.expected {
+			t.Errorf("%d: expected %q got %q", idx, tt.expected, got)
+		}
+
+		if d.BytesRead() != len(tt.expected) {
+			t.Errorf("%d: expected %d file bytes read got %d", idx, len(tt.expected), d.BytesRead())
+		}
+	}
+}
+
+func TestScanASCII(t *testing.T) {
+	// sent as "ab\r\ncd\r\n"
+	file := []byte("ab\ncd\n")
+
+	var tests = []struct {
+		limit  int64
+		offset int64
+		ascii  int64
+		cr     bool
+	}{
+		{0, 0, 0, false},
+		{2, 2, 2, false},
+		// between the CR and LF
+		{3, 2, 3, true},
+		{4, 3, 4, false},
+		{8, 6, 8, false},
+		{20, 6, 8, false},
+		{-1, 6, 8, false},
+	}
+
+	for idx, tt := range tests {
+		// a small buffer so the file takes more than one read
+		offset, ascii, cr, err := scanASCII(bytes.NewReader(file), tt.limit, make([]byte, 3))
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %s", idx, err)
+		}
+
+		if offset != tt.offset || ascii != tt.ascii || cr != tt.cr {
+			t.Errorf("%d: expected %d %d %t got %d %d %t", idx, tt.offset, tt.ascii, tt.cr, offset, ascii, cr)
+		}
+	}
+}
+
+func TestASCIITransfers(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/a.txt", []byte("ab\ncd\n"))
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "TYPE A")
+
+	if msg := c.cmd(213, "SIZE a.txt"); msg != "213 8" {
+		t.Errorf("expected 213 8 got %q", msg)
+	}
+
+	var tests = []struct {
+		rest     int
+		expected string
+	}{
+		{0, "ab\r\ncd\r\n"},
+		// lands between the CR and LF, so only the LF is sent
+		{3, "\ncd\r\n"},
+		{4, "cd\r\n"},
+	}
+
+	for _, tt := range tests {
+		c.cmd(350, "REST %d", tt.rest)
+
+		if got := c.list("RETR a.txt"); got != tt.expected {
+			t.Errorf("REST %d expected %q got %q", tt.rest, tt.expected, got)
+		}
+	}
+
+	data := c.pasv()
+	c.cmd(150, "STOR b.txt")
+	data.Write([]byte("ef\r\ngh\r\n"))
+	data.Close()
+	c.expect(226)
+
+	f, err := ts.mem.Open("/b.txt")
+	if err != nil {
+		t.Fatalf("unexpected error opening file: %s", err)
+	}
+	defer f.Close()
+
+	if got, _ := ioutil.ReadAll(f); string(got) != "ef\ngh\n" {
+		t.Errorf("expected the file stored with LF got %q", got)
+	}
+}
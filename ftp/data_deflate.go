@@ -0,0 +1,109 @@
+package ftp
+
+import (
+	"compress/zlib"
+	"io"
+	"sync/atomic"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// deflateDataConn wraps a cmd.DataConn for MODE Z, compressing anything
+// written and decompressing anything read. BytesRead and BytesWritten count
+// the file bytes, the Wire counters those of the underlying connection
+type deflateDataConn struct {
+	cmd.DataConn
+
+	level int
+
+	reader io.ReadCloser
+	writer *zlib.Writer
+
+	written int64
+	read    int64
+
+	// used is set on the first Read or Write, an empty Write is how a
+	// transfer of nothing asks for an empty stream
+	used   bool
+	closed bool
+}
+
+func newDeflateDataConn(d cmd.DataConn, level int) *deflateDataConn {
+	return &deflateDataConn{
+		DataConn: d,
+		level:    level,
+	}
+}
+
+// Read implements the io.Reader interface, the zlib header is only read on the
+// first call so that we don't block before the transfer starts
+func (d *deflateDataConn) Read(p []byte) (int, error) {
+	d.used = true
+
+	if d.reader == nil {
+		r, err := zlib.NewReader(d.DataConn)
+		if err != nil {
+			// the client sent nothing at all, treat it as an empty file
+			if err == io.ErrUnexpectedEOF && d.DataConn.BytesRead() == 0 {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		d.reader = r
+	}
+
+	n, err := d.reader.Read(p)
+	atomic.AddInt64(&d.read, int64(n))
+
+	return n, err
+}
+
+// Write implements the io.Writer interface
+func (d *deflateDataConn) Write(p []byte) (int, error) {
+	d.used = true
+
+	if d.writer == nil {
+		w, err := zlib.NewWriterLevel(d.DataConn, d.level)
+		if err != nil {
+			return 0, err
+		}
+		d.writer = w
+	}
+
+	n, err := d.writer.Write(p)
+	atomic.AddInt64(&d.written, int64(n))
+
+	return n, err
+}
+
+// Close finishes the compressed stream before closing the underlying
+// connection. If it was never used only the underlying connection is
+// closed, it may not have been connected to
+func (d *deflateDataConn) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+
+	if !d.used {
+		return d.DataConn.Close()
+	}
+
+	if d.writer != nil {
+		if err := d.writer.Close(); err != nil {
+			d.DataConn.Close()
+			return err
+		}
+	}
+
+	if d.reader != nil {
+		d.reader.Close()
+	}
+
+	return d.DataConn.Close()
+}
+
+func (d *deflateDataConn) BytesRead() int        { return int(atomic.LoadInt64(&d.read)) }
+func (d *deflateDataConn) BytesWritten() int     { return int(atomic.LoadInt64(&d.written)) }
+func (d *deflateDataConn) WireBytesRead() int    { return d.DataConn.BytesRead() }
+func (d *deflateDataConn) WireBytesWritten() int { return d.DataConn.BytesWritten() }
@@ -0,0 +1,86 @@
+package ftp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io/ioutil"
+	"testing"
+)
+
+// bufferDataConn is a cmd.DataConn writing to a buffer
+type bufferDataConn struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (d *bufferDataConn) Host() string          { return "127.0.0.1" }
+func (d *bufferDataConn) Port() int             { return 0 }
+func (d *bufferDataConn) Kind() string          { return "Buffer" }
+func (d *bufferDataConn) BytesRead() int        { return 0 }
+func (d *bufferDataConn) BytesWritten() int     { return d.Len() }
+func (d *bufferDataConn) WireBytesRead() int    { return 0 }
+func (d *bufferDataConn) WireBytesWritten() int { return d.Len() }
+func (d *bufferDataConn) Close() error          { d.closed = true; return nil }
+
+func TestDeflateCloseUnused(t *testing.T) {
+	var conn bufferDataConn
+
+	if err := newDeflateDataConn(&conn, 6).Close(); err != nil {
+		t.Fatalf("unexpected error closing: %s", err)
+	}
+
+	if !conn.closed {
+		t.Fatal("expected the underlying connection to be closed")
+	}
+
+	if conn.Len() != 0 {
+		t.Fatalf("expected nothing written, got %d bytes", conn.Len())
+	}
+}
+
+func TestDeflateEmptyWrite(t *testing.T) {
+	var conn bufferDataConn
+
+	d := newDeflateDataConn(&conn, 6)
+
+	if _, err := d.Write(nil); err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %s", err)
+	}
+
+	r, err := zlib.NewReader(&conn.Buffer)
+	if err != nil {
+		t.Fatalf("expected an empty zlib stream: %s", err)
+	}
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("expected an empty stream, got %q, %v", got, err)
+	}
+}
+
+func TestDeflateRETREmptyFile(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/empty.bin", nil)
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "TYPE I")
+	c.cmd(200, "MODE Z")
+
+	data := c.pasv()
+	c.cmd(150, "RETR empty.bin")
+
+	r, err := zlib.NewReader(data)
+	if err != nil {
+		t.Fatalf("expected an empty zlib stream: %s", err)
+	}
+
+	if got, err := ioutil.ReadAll(r); err != nil || len(got) != 0 {
+		t.Fatalf("expected an empty stream, got %q, %v", got, err)
+	}
+
+	c.expect(226)
+}
@@ -0,0 +1,114 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// peerConn is implemented by data connections that know who is on the other
+// end, passive connections wait to be accepted
+type peerConn interface {
+	peerIP() (net.IP, error)
+}
+
+// fxpDataConn checks the data connection peer before the first Read or Write.
+// Wrapping the connection means we don't dial out for PORT, or send anything
+// for PASV, before the check has been made
+type fxpDataConn struct {
+	cmd.DataConn
+
+	check func() error
+
+	once sync.Once
+	err  error
+}
+
+func newFXPDataConn(d cmd.DataConn, check func() error) *fxpDataConn {
+	return &fxpDataConn{
+		DataConn: d,
+		check:    check,
+	}
+}
+
+// Read implements the io.Reader interface
+func (d *fxpDataConn) Read(p []byte) (int, error) {
+	if d.once.Do(func() { d.err = d.check() }); d.err != nil {
+		return 0, d.err
+	}
+	return d.DataConn.Read(p)
+}
+
+// Write implements the io.Writer interface
+func (d *fxpDataConn) Write(p []byte) (int, error) {
+	if d.once.Do(func() { d.err = d.check() }); d.err != nil {
+		return 0, d.err
+	}
+	return d.DataConn.Write(p)
+}
+
+// ZeroCopy implements cmd.ZeroCopier when the connection it wraps does
+func (d *fxpDataConn) ZeroCopy(r io.Reader) (int64, bool, error) {
+	zc, ok := d.DataConn.(cmd.ZeroCopier)
+	if !ok {
+		return 0, false, nil
+	}
+
+	if d.once.Do(func() { d.err = d.check() }); d.err != nil {
+		return 0, true, d.err
+	}
+
+	return zc.ZeroCopy(r)
+}
+
+// remoteIP returns the IP of the other end of a connection
+func remoteIP(conn net.Conn) net.IP {
+	return addrIP(conn.RemoteAddr())
+}
+
+// addrIP returns the IP of an address
+func addrIP(addr net.Addr) net.IP {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+var ErrFXPNotAllowed = errors.New("data connection must be with the same host as the control connection")
+
+// checkDataPeer makes sure the data connection is with the client, unless they
+// are allowed to FXP the file. Only RETR, STOR and APPE can FXP
+func (s *Session) checkDataPeer(d peerConn, ftpCommand, path string) error {
+	ip, err := d.peerIP()
+	if err != nil {
+		return err
+	}
+
+	// through a bouncer the data connection can be with either
+	if ip.Equal(remoteIP(s.control)) || ip.Equal(addrIP(s.RemoteAddr())) {
+		return nil
+	}
+
+	user := s.User()
+	if user == nil {
+		return errors.New("no user found")
+	}
+
+	switch ftpCommand {
+	case "RETR":
+		if err := s.FS().CheckFXP(path, false, user); err == nil {
+			return nil
+		}
+
+	case "STOR", "APPE":
+		if err := s.FS().CheckFXP(path, true, user); err == nil {
+			return nil
+		}
+	}
+
+	return ErrFXPNotAllowed
+}
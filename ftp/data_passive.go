@@ -11,31 +11,49 @@ import (
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
+var ErrDataAcceptTimeout = errors.New("timed out waiting for the data connection")
+
 type passiveDataConn struct {
-	ctx context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	conn net.Conn
 
-	dataProtected bool
+	// ready is closed once Accept has finished, successfully or not
+	ready chan struct{}
+
+	// set when the data connection is protected, tlsClient swaps our role
+	// in the handshake for SSCN and CPSV
+	tlsConfig *tls.Config
+	tlsClient bool
 
 	host string
 	port int64
 
 	onClose func()
 
-	written int
-	read    int
+	// how long to wait for the client to connect, and for a Read or
+	// Write before giving up on a stalled transfer
+	acceptTimeout time.Duration
+	stallTimeout  time.Duration
+
+	written int64
+	read    int64
 
 	err error
 
-	sync.Mutex
+	closeOnce sync.Once
 }
 
-func (s *Server) newPassiveDataConn(ctx context.Context, dataProtected bool) (*passiveDataConn, error) {
+// newPassiveDataConn listens on a free passive port. If dataProtected is set the
+// connection is upgraded to TLS once accepted, as the TLS client if tlsClient
+// is set
+func (s *Server) newPassiveDataConn(ctx context.Context, l *listener, dataProtected, tlsClient bool) (*passiveDataConn, error) {
 	var count int
 	for {
 		if count > 1000 {
@@ -43,58 +61,69 @@ func (s *Server) newPassiveDataConn(ctx context.Context, dataProtected bool) (*p
 		}
 		count++
 
-		n, err := rand.Int(rand.Reader, s.passivePortsMax)
+		n, err := rand.Int(rand.Reader, l.passivePortsMax)
 		if err != nil {
 			return nil, err
 		}
 
-		s.passivePortsMtx.Lock()
-		_, ok := s.passivePorts[n.Int64()]
+		l.passivePortsMtx.Lock()
+		_, ok := l.passivePorts[n.Int64()]
 
 		// we keep the lock open so we dont
 		// have to worry about race conditions on
 		// setting the value in the map
 
 		if ok {
-			s.passivePortsMtx.Unlock()
+			l.passivePortsMtx.Unlock()
 			continue
 		} else {
-			s.passivePorts[n.Int64()] = struct{}{}
+			l.passivePorts[n.Int64()] = struct{}{}
 		}
 
-		s.passivePortsMtx.Unlock()
+		l.passivePortsMtx.Unlock()
 
-		port := n.Int64() + int64(s.PassivePorts[0])
+		offset := n.Int64()
+		release := func() {
+			l.passivePortsMtx.Lock()
+			delete(l.passivePorts, offset)
+			l.passivePortsMtx.Unlock()
+		}
 
-		// if we want to support none tls, do it here
-		var ln net.Listener
+		port := offset + int64(l.PassivePorts[0])
 
-		addr := net.JoinHostPort(s.BindIP, strconv.Itoa(int(port)))
+		addr := net.JoinHostPort(l.BindIP, strconv.Itoa(int(port)))
 
-		if dataProtected {
-			ln, err = tls.Listen("tcp", addr, s.tlsConfig)
-		} else {
-			ln, err = net.Listen("tcp", addr)
-		}
+		ln, err := net.Listen("tcp", addr)
 
 		// check listen error
 		if err != nil {
+			release()
 			if isErrorAddressAlreadyInUse(err) {
 				continue
 			}
 			return nil, err
 		}
 
+		ctx, cancel := context.WithCancel(ctx)
+
 		dc := passiveDataConn{
-			ctx:           ctx,
-			host:          s.PublicIP,
-			port:          port,
-			dataProtected: dataProtected,
-			onClose: func() {
-				s.passivePortsMtx.Lock()
-				delete(s.passivePorts, port)
-				s.passivePortsMtx.Unlock()
-			},
+			ctx:       ctx,
+			cancel:    cancel,
+			ready:     make(chan struct{}),
+			host:      l.PublicIP,
+			port:      port,
+			tlsClient: tlsClient,
+			onClose:   release,
+
+			acceptTimeout: time.Duration(s.options().DataAcceptTimeout) * time.Second,
+			stallTimeout:  time.Duration(s.options().StallTimeout) * time.Second,
+		}
+
+		if dataProtected {
+			dc.tlsConfig = s.TLSConfig()
+			if tlsClient {
+				dc.tlsConfig = s.tlsClientConfig()
+			}
 		}
 
 		go dc.Accept(ctx, ln)
@@ -107,36 +136,42 @@ func (s *Server) newPassiveDataConn(ctx context.Context, dataProtected bool) (*p
 }
 
 // Close implements the io.Closer interface and also allows us
-// to call our onClose fn that will cleanup server state
+// to call our onClose fn that will cleanup server state. It is safe
+// to call while a Read or Write is blocked, which is how ABOR interrupts
+// a running transfer
 func (d *passiveDataConn) Close() error {
+	var err error
 
-	d.Lock()
-	defer d.Unlock()
+	d.closeOnce.Do(func() {
+		// stop any pending accept and wait for it to give up
+		d.cancel()
+		<-d.ready
 
-	if d.conn != nil {
-		if err := d.conn.Close(); err != nil {
-			return err
+		if d.conn != nil {
+			err = d.conn.Close()
 		}
-	}
 
-	if d.onClose != nil {
-		d.onClose()
-	}
+		if d.onClose != nil {
+			d.onClose()
+		}
+	})
 
-	return nil
+	return err
 }
 
-// Accept makes passiveDataConn context aware as well as concurrent. It
-// locks the socket to prevent races on the underlying conn
+// Accept makes passiveDataConn context aware as well as concurrent. Read
+// and Write wait for it to finish before using the underlying conn
 func (d *passiveDataConn) Accept(ctx context.Context, ln net.Listener) {
-	d.Lock()
-	defer d.Unlock()
+	defer close(d.ready)
 
 	// always close the listener
 	defer ln.Close()
 
-	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(time.Second*60))
-	defer cancel()
+	if d.acceptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.acceptTimeout)
+		defer cancel()
+	}
 
 	// make accept context aware
 	go func() {
@@ -144,13 +179,45 @@ func (d *passiveDataConn) Accept(ctx context.Context, ln net.Listener) {
 		ln.Close()
 	}()
 
-	d.conn, d.err = ln.Accept()
+	conn, err := ln.Accept()
+	if err != nil {
+		d.err = err
+		if ctx.Err() == context.DeadlineExceeded {
+			d.err = ErrDataAcceptTimeout
+		}
+		return
+	}
+
+	if d.tlsConfig != nil {
+		var tlsConn *tls.Conn
+		if d.tlsClient {
+			tlsConn = tls.Client(conn, d.tlsConfig)
+		} else {
+			tlsConn = tls.Server(conn, d.tlsConfig)
+		}
 
-	if d.dataProtected {
 		// handshake
-		if err := d.conn.(*tls.Conn).Handshake(); err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR HANDSHAKE PASV: %s", err)
+		if err := tlsConn.Handshake(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR HANDSHAKE PASV: %s\n", err)
+			conn.Close()
+			d.err = err
+			return
 		}
+
+		conn = tlsConn
+	}
+
+	d.conn = conn
+}
+
+// wait blocks until the connection has been accepted, returning any
+// accept error
+func (d *passiveDataConn) wait() error {
+	select {
+	case <-d.ready:
+		return d.err
+	case <-d.ctx.Done():
+		return d.ctx.Err()
 	}
 }
 
@@ -161,15 +228,18 @@ func (d *passiveDataConn) Read(p []byte) (int, error) {
 		return 0, err
 	}
 
-	d.Lock()
-	defer d.Unlock()
+	if err := d.wait(); err != nil {
+		return 0, err
+	}
 
-	if d.err != nil {
-		return 0, d.err
+	if d.stallTimeout > 0 {
+		if err := d.conn.SetReadDeadline(time.Now().Add(d.stallTimeout)); err != nil {
+			return 0, err
+		}
 	}
 
 	n, err := d.conn.Read(p)
-	d.read += n
+	atomic.AddInt64(&d.read, int64(n))
 
 	return n, err
 }
@@ -181,15 +251,18 @@ func (d *passiveDataConn) Write(p []byte) (int, error) {
 		return 0, err
 	}
 
-	d.Lock()
-	defer d.Unlock()
+	if err := d.wait(); err != nil {
+		return 0, err
+	}
 
-	if d.err != nil {
-		return 0, d.err
+	if d.stallTimeout > 0 {
+		if err := d.conn.SetWriteDeadline(time.Now().Add(d.stallTimeout)); err != nil {
+			return 0, err
+		}
 	}
 
 	n, err := d.conn.Write(p)
-	d.written += n
+	atomic.AddInt64(&d.written, int64(n))
 
 	return n, err
 }
@@ -223,8 +296,18 @@ func isErrorAddressAlreadyInUse(err error) bool {
 	return false
 }
 
-func (d *passiveDataConn) Host() string      { return d.host }
-func (d *passiveDataConn) Port() int         { return int(d.port) }
-func (d *passiveDataConn) BytesRead() int    { return d.read }
-func (d *passiveDataConn) BytesWritten() int { return d.written }
-func (d *passiveDataConn) Kind() string      { return "Passive" }
+// peerIP waits for the connection and returns the address it came from
+func (d *passiveDataConn) peerIP() (net.IP, error) {
+	if err := d.wait(); err != nil {
+		return nil, err
+	}
+	return remoteIP(d.conn), nil
+}
+
+func (d *passiveDataConn) Host() string          { return d.host }
+func (d *passiveDataConn) Port() int             { return int(d.port) }
+func (d *passiveDataConn) BytesRead() int        { return int(atomic.LoadInt64(&d.read)) }
+func (d *passiveDataConn) BytesWritten() int     { return int(atomic.LoadInt64(&d.written)) }
+func (d *passiveDataConn) WireBytesRead() int    { return d.BytesRead() }
+func (d *passiveDataConn) WireBytesWritten() int { return d.BytesWritten() }
+func (d *passiveDataConn) Kind() string          { return "Passive" }
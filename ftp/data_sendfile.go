@@ -0,0 +1,101 @@
+package ftp
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// sendFileChunk is how much is sent by each sendfile, the stall deadline is
+// set before each one, the same as a Write of a buffer
+const sendFileChunk = 256 * 1024
+
+// sendFile sends r over conn with sendfile when conn is plain TCP and r is a
+// file on disk, or an io.LimitedReader of one, counting what was sent in
+// written. Returns false without sending anything otherwise
+func sendFile(conn net.Conn, r io.Reader, stallTimeout time.Duration, written *int64) (int64, bool, error) {
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return 0, false, nil
+	}
+
+	src, limit := r, int64(-1)
+
+	lr, limited := r.(*io.LimitedReader)
+	if limited {
+		src, limit = lr.R, lr.N
+	}
+
+	f, ok := vfs.OSFile(src)
+	if !ok {
+		return 0, false, nil
+	}
+
+	var total int64
+
+	for limit != 0 {
+		chunk := int64(sendFileChunk)
+		if limit > 0 && limit < chunk {
+			chunk = limit
+		}
+
+		if stallTimeout > 0 {
+			if err := tcp.SetWriteDeadline(time.Now().Add(stallTimeout)); err != nil {
+				return total, true, err
+			}
+		}
+
+		// net uses sendfile for an io.LimitedReader of an *os.File
+		n, err := tcp.ReadFrom(&io.LimitedReader{R: f, N: chunk})
+		total += n
+		atomic.AddInt64(written, n)
+
+		if limit > 0 {
+			limit -= n
+		}
+
+		if err != nil {
+			return total, true, err
+		}
+
+		// end of the file
+		if n < chunk {
+			break
+		}
+	}
+
+	if limited {
+		lr.N -= total
+	}
+
+	return total, true, nil
+}
+
+// ZeroCopy implements cmd.ZeroCopier
+func (d *passiveDataConn) ZeroCopy(r io.Reader) (int64, bool, error) {
+	if err := d.ctx.Err(); err != nil {
+		return 0, true, err
+	}
+
+	if err := d.wait(); err != nil {
+		return 0, true, err
+	}
+
+	return sendFile(d.conn, r, d.stallTimeout, &d.written)
+}
+
+// ZeroCopy implements cmd.ZeroCopier
+func (d *activeDataConn) ZeroCopy(r io.Reader) (int64, bool, error) {
+	if err := d.ctx.Err(); err != nil {
+		return 0, true, err
+	}
+
+	if err := d.connect(); err != nil {
+		return 0, true, err
+	}
+
+	return sendFile(d.conn, r, d.stallTimeout, &d.written)
+}
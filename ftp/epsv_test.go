@@ -0,0 +1,22 @@
+package ftp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEPSVProtocol(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	c := ts.login(t, "alice")
+
+	c.cmd(229, "EPSV")
+	c.cmd(229, "EPSV 1")
+
+	// the control connection is IPv4
+	if msg := c.cmd(522, "EPSV 2"); !strings.Contains(msg, "use (1)") {
+		t.Errorf("expected the supported protocol in %q", msg)
+	}
+
+	c.cmd(522, "EPSV 3")
+}
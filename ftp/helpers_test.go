@@ -0,0 +1,321 @@
+package ftp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/script"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// testServer is a Server with an in memory filesystem and authenticator,
+// serving control connections accepted on a loopback port
+type testServer struct {
+	*Server
+
+	mem billy.Filesystem
+	ln  net.Listener
+}
+
+func openMemoryDB(t *testing.T) *badger.DB {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	return db
+}
+
+func newTestPermissions(t *testing.T, lines []string) *acl.Permissions {
+	t.Helper()
+
+	var rules []acl.Rule
+	for _, l := range lines {
+		r, err := acl.NewRule(l)
+		if err != nil {
+			t.Fatalf("unexpected error creating NewRule: %s", err)
+		}
+		rules = append(rules, r)
+	}
+
+	return acl.NewPermissions(rules)
+}
+
+// defaultTestRules let everyone download from and upload to the test server
+var defaultTestRules = []string{
+	"download /** *",
+	"upload /** *",
+	"resume /** *",
+	"resumeown /** *",
+	"delete /** *",
+	"deleteown /** *",
+	"makedir /** *",
+	"fxpdownload /** *",
+	"fxpupload /** *",
+}
+
+// newTestServer starts a server everyone can download from and upload to,
+// configure can change the options before it is created
+func newTestServer(t *testing.T, configure func(*ServerOpts)) *testServer {
+	t.Helper()
+
+	return newTestServerWithRules(t, defaultTestRules, configure)
+}
+
+// newTestServerWithRules starts a server with the permission rules
+func newTestServerWithRules(t *testing.T, rules []string, configure func(*ServerOpts)) *testServer {
+	t.Helper()
+
+	mem := memfs.New()
+
+	perms := newTestPermissions(t, rules)
+
+	fsOpts := vfs.FilesystemOpts{
+		DefaultUser:  "nobody",
+		DefaultGroup: "nogroup",
+	}
+
+	fs, err := vfs.NewFilesystem(&fsOpts, mem, vfs.NewShadowStore(openMemoryDB(t)), perms)
+	if err != nil {
+		t.Fatalf("unexpected error creating NewFilesystem: %s", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error listening: %s", err)
+	}
+
+	passive := 40000 + int(time.Now().UnixNano()%20000)
+
+	opts := ServerOpts{
+		PublicIP: "127.0.0.1",
+		BindIP:   "127.0.0.1",
+		Listeners: []*ListenerOpts{
+			{
+				Name:           "test",
+				Host:           "127.0.0.1",
+				AllowPlaintext: true,
+				PassivePorts:   []int{passive, passive + 1000},
+				PublicIP:       "127.0.0.1",
+				BindIP:         "127.0.0.1",
+			},
+		},
+		IdleTimeout:        60,
+		LoginTimeout:       60,
+		DataAcceptTimeout:  10,
+		DataConnectTimeout: 10,
+		StallTimeout:       10,
+	}
+
+	if configure != nil {
+		configure(&opts)
+	}
+
+	server, err := NewServer(&opts, fs, acl.NewBadgerAuthenticator(openMemoryDB(t)), &script.DummyEngine{})
+	if err != nil {
+		t.Fatalf("unexpected error creating NewServer: %s", err)
+	}
+
+	ts := &testServer{
+		Server: server,
+		mem:    mem,
+		ln:     ln,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.handleConnection(ctx, server.listeners[0], conn)
+		}
+	}()
+
+	t.Cleanup(func() {
+		ln.Close()
+		cancel()
+		server.Shutdown(context.Background())
+		fs.Stop()
+		server.auth.Stop()
+	})
+
+	return ts
+}
+
+// addUser creates a user that can log in from loopback
+func (ts *testServer) addUser(t *testing.T, name, pass string) {
+	t.Helper()
+
+	if _, err := ts.auth.AddUser(name, pass); err != nil {
+		t.Fatalf("unexpected error adding user: %s", err)
+	}
+
+	err := ts.auth.UpdateUser(name, func(u *acl.User) error {
+		return u.AddIP("*@127.0.0.1")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error adding ip: %s", err)
+	}
+}
+
+func (ts *testServer) createFile(t *testing.T, path string, contents []byte) {
+	t.Helper()
+
+	f, err := ts.mem.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatalf("unexpected err creating %s: %s", path, err)
+	}
+
+	if _, err := f.Write(contents); err != nil {
+		t.Fatalf("unexpected err writing %s: %s", path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected err closing %s: %s", path, err)
+	}
+}
+
+// testClient speaks just enough FTP to drive a session
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (ts *testServer) dial(t *testing.T) *testClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", ts.ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %s", err)
+	}
+
+	c := &testClient{
+		t:      t,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	c.expect(220)
+
+	return c
+}
+
+// login dials and logs in as a new user
+func (ts *testServer) login(t *testing.T, name string) *testClient {
+	t.Helper()
+
+	if _, err := ts.auth.GetUser(name); err != nil {
+		ts.addUser(t, name, "pass")
+	}
+
+	c := ts.dial(t)
+	c.cmd(331, "USER %s", name)
+	c.cmd(230, "PASS pass")
+
+	return c
+}
+
+// read returns the code and lines of the next reply
+func (c *testClient) read() (int, string) {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(time.Second * 10))
+
+	var lines []string
+
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("unexpected error reading reply: %s (got %q)", err, lines)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+
+		if len(line) >= 4 && line[3] == ' ' {
+			if code, err := strconv.Atoi(line[:3]); err == nil && (len(lines) == 1 || strings.HasPrefix(lines[0], line[:3]+"-")) {
+				return code, strings.Join(lines, "\n")
+			}
+		}
+	}
+}
+
+func (c *testClient) send(format string, args ...interface{}) {
+	c.t.Helper()
+
+	if _, err := fmt.Fprintf(c.conn, format+"\r\n", args...); err != nil {
+		c.t.Fatalf("unexpected error sending: %s", err)
+	}
+}
+
+// expect reads a reply, failing unless it has the code
+func (c *testClient) expect(code int) string {
+	c.t.Helper()
+
+	got, msg := c.read()
+	if got != code {
+		c.t.Fatalf("expected %d but got %q", code, msg)
+	}
+
+	return msg
+}
+
+// cmd sends a command and expects code in reply
+func (c *testClient) cmd(code int, format string, args ...interface{}) string {
+	c.t.Helper()
+
+	c.send(format, args...)
+
+	return c.expect(code)
+}
+
+var pasvRE = regexp.MustCompile(`\((\d+),(\d+),(\d+),(\d+),(\d+),(\d+)\)`)
+
+// pasv sends PASV and connects to the port it replies with
+func (c *testClient) pasv() net.Conn {
+	c.t.Helper()
+
+	msg := c.cmd(227, "PASV")
+
+	m := pasvRE.FindStringSubmatch(msg)
+	if m == nil {
+		c.t.Fatalf("unexpected PASV reply %q", msg)
+	}
+
+	p1, _ := strconv.Atoi(m[5])
+	p2, _ := strconv.Atoi(m[6])
+
+	addr := fmt.Sprintf("%s.%s.%s.%s:%d", m[1], m[2], m[3], m[4], p1*256+p2)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		c.t.Fatalf("unexpected error dialing data %s: %s", addr, err)
+	}
+
+	c.t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
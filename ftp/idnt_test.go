@@ -0,0 +1,123 @@
+package ftp
+
+import (
+	"testing"
+)
+
+// newIdntTestServer trusts IDNT from the test client
+func newIdntTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	return newTestServer(t, func(opts *ServerOpts) {
+		opts.Listeners[0].IdntFrom = []string{"127.0.0.1"}
+	})
+}
+
+// idnt returns what the session not yet logged in took from IDNT
+func (ts *testServer) idnt(t *testing.T) (string, string, string) {
+	t.Helper()
+
+	s, entry := ts.entry(t, "")
+
+	ts.sessionsMtx.Lock()
+	defer ts.sessionsMtx.Unlock()
+
+	return entry.ident, entry.addr, s.hostname
+}
+
+func TestIDNTUntrusted(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	c := ts.dial(t)
+	c.cmd(502, "IDNT bob@10.0.0.1:example.com")
+
+	// carries on as the bouncer
+	c.cmd(331, "USER alice")
+
+	ident, addr, hostname := ts.idnt(t)
+	if len(ident) > 0 || len(hostname) > 0 || addr == "10.0.0.1:0" {
+		t.Fatalf("expected IDNT to be ignored, got %q %q %q", ident, addr, hostname)
+	}
+}
+
+func TestIDNTRepeated(t *testing.T) {
+	ts := newIdntTestServer(t)
+
+	c := ts.dial(t)
+	c.send("IDNT bob@10.0.0.1:example.com")
+	c.cmd(503, "IDNT eve@10.0.0.2:example.org")
+
+	ident, addr, hostname := ts.idnt(t)
+	if ident != "bob" || addr != "10.0.0.1:0" || hostname != "example.com" {
+		t.Fatalf("expected the first IDNT to be kept, got %q %q %q", ident, addr, hostname)
+	}
+}
+
+func TestIDNT(t *testing.T) {
+	tests := []struct {
+		params   string
+		ident    string
+		addr     string
+		hostname string
+	}{
+		{"bob@10.0.0.1:example.com", "bob", "10.0.0.1:0", "example.com"},
+		{"bob@10.0.0.1", "bob", "10.0.0.1:0", ""},
+		{"@10.0.0.1:example.com", "*", "10.0.0.1:0", "example.com"},
+		{"bob@::1", "bob", "[::1]:0", ""},
+		{"bob@2001:db8::1", "bob", "[2001:db8::1]:0", ""},
+		{"bob@[2001:db8::1]", "bob", "[2001:db8::1]:0", ""},
+		{"bob@[2001:db8::1]:example.com", "bob", "[2001:db8::1]:0", "example.com"},
+		{"bob@::ffff:10.0.0.1:example.com", "bob", "10.0.0.1:0", "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.params, func(t *testing.T) {
+			ts := newIdntTestServer(t)
+
+			c := ts.dial(t)
+
+			// nothing is sent back on success
+			c.send("IDNT %s", tt.params)
+			c.cmd(331, "USER alice")
+
+			ident, addr, hostname := ts.idnt(t)
+			if ident != tt.ident || addr != tt.addr || hostname != tt.hostname {
+				t.Fatalf(
+					"expected %q %q %q got %q %q %q",
+					tt.ident, tt.addr, tt.hostname,
+					ident, addr, hostname,
+				)
+			}
+		})
+	}
+}
+
+func TestIDNTBad(t *testing.T) {
+	tests := []string{
+		"IDNT bob",
+		"IDNT bob10.0.0.1:example.com",
+		"IDNT",
+		"IDNT bob@example.com",
+		"IDNT bob@example.com:10.0.0.1",
+		"IDNT bob@[2001:db8::1",
+		"IDNT bob@[2001:db8::1]example.com",
+		"IDNT bob@[example.com]:example.com",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			ts := newIdntTestServer(t)
+
+			c := ts.dial(t)
+			c.cmd(501, tt)
+
+			// still allowed to try again
+			c.send("IDNT bob@10.0.0.1")
+			c.cmd(331, "USER alice")
+
+			if ident, _, _ := ts.idnt(t); ident != "bob" {
+				t.Fatalf("expected ident bob got %q", ident)
+			}
+		})
+	}
+}
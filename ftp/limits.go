@@ -0,0 +1,226 @@
+package ftp
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+var (
+	ErrShuttingDown         = errors.New("server is shutting down")
+	ErrTooManySessions      = errors.New("the site is full")
+	ErrTooManySessionsIP    = errors.New("too many connections from your address")
+	ErrTooManyLoginsUser    = errors.New("too many logins for your user")
+	ErrTooManyLoginsGroup   = errors.New("too many logins for your group")
+	errSessionNotRegistered = errors.New("session not registered")
+)
+
+// sessionEntry is what the server keeps about each session to enforce the
+// connection limits and for SITE WHO. Guarded by sessionsMtx, the session
+// updates it from its control goroutine so others don't touch its state
+type sessionEntry struct {
+	id        uint64
+	ip        string
+	bouncer   bool
+	connected time.Time
+
+	// set once logged in
+	user  string
+	group string
+
+	// updated with each command, see updateEntry
+	addr    string
+	ident   string
+	command string
+	cwd     string
+
+	// set once it has been picked to be disconnected, so it only is once
+	killed bool
+}
+
+// addSession registers a new session, failing if it would go over
+// max_sessions, including the headroom kept for exempt users, or
+// max_sessions_per_ip. Connections from trusted bouncers carry lots of
+// clients so aren't counted per ip
+func (server *Server) addSession(s *Session) error {
+	entry := sessionEntry{
+		ip:        remoteIP(s.control).String(),
+		bouncer:   s.TrustedBouncer(),
+		connected: s.connectedAt,
+		addr:      s.RemoteAddr().String(),
+		cwd:       s.currentDir,
+	}
+
+	opts := server.options()
+
+	server.sessionsMtx.Lock()
+	defer server.sessionsMtx.Unlock()
+
+	if server.shuttingDown() {
+		return ErrShuttingDown
+	}
+
+	if opts.MaxSessions > 0 && len(server.sessions) >= opts.MaxSessions+opts.MaxSessionsExempt {
+		return ErrTooManySessions
+	}
+
+	if opts.MaxSessionsPerIP > 0 && !entry.bouncer {
+		var count int
+		for _, e := range server.sessions {
+			if e.ip == entry.ip && !e.bouncer {
+				count++
+			}
+		}
+
+		if count >= opts.MaxSessionsPerIP {
+			return ErrTooManySessionsIP
+		}
+	}
+
+	server.lastSessionID++
+	entry.id = server.lastSessionID
+
+	server.sessions[s] = &entry
+
+	s.controlMtx.Lock()
+	s.id = entry.id
+	s.controlMtx.Unlock()
+
+	return nil
+}
+
+// removeSession stops counting the session
+func (server *Server) removeSession(s *Session) {
+	server.sessionsMtx.Lock()
+	delete(server.sessions, s)
+	server.sessionsMtx.Unlock()
+}
+
+// ReserveLogin checks the login limits for the user and if they aren't
+// exceeded counts the session as theirs. The user limit is User.Logins and
+// the group one Group.Logins for their primary group, either falls back to
+// the server default when 0. Users matching limits_exempt are only limited
+// by the connection limits checked by addSession
+func (s *Session) ReserveLogin(user *acl.User) error {
+	server := s.server
+	opts := server.options()
+
+	userMax := user.Logins
+	if userMax == 0 {
+		userMax = opts.MaxLoginsPerUser
+	}
+
+	groupMax := opts.MaxLoginsPerGroup
+	if len(user.PrimaryGroup) > 0 {
+		if g, err := server.auth.GetGroup(user.PrimaryGroup); err == nil && g.Logins > 0 {
+			groupMax = g.Logins
+		}
+	}
+
+	exempt := opts.limitsExempt != nil && opts.limitsExempt.Match(user)
+
+	name := strings.ToLower(user.Name)
+	group := strings.ToLower(user.PrimaryGroup)
+
+	server.sessionsMtx.Lock()
+	defer server.sessionsMtx.Unlock()
+
+	entry, ok := server.sessions[s]
+	if !ok {
+		return errSessionNotRegistered
+	}
+
+	if !exempt {
+		// the headroom over max_sessions is only for exempt users
+		if opts.MaxSessions > 0 && len(server.sessions) > opts.MaxSessions {
+			return ErrTooManySessions
+		}
+
+		var users, groups int
+		for other, e := range server.sessions {
+			if other == s {
+				continue
+			}
+
+			if e.user == name {
+				users++
+			}
+
+			if len(group) > 0 && e.group == group {
+				groups++
+			}
+		}
+
+		if userMax > 0 && users >= userMax {
+			return ErrTooManyLoginsUser
+		}
+
+		if groupMax > 0 && groups >= groupMax {
+			return ErrTooManyLoginsGroup
+		}
+	}
+
+	entry.user = name
+	entry.group = group
+
+	return nil
+}
+
+var (
+	ErrTooManyUploads   = errors.New("too many simultaneous uploads")
+	ErrTooManyDownloads = errors.New("too many simultaneous downloads")
+)
+
+// ReserveTransfer takes an upload or download slot for the user, counted
+// across all of their sessions, the returned func gives it back. The limit
+// is User.Uploads or User.Downloads, falling back to their primary group's
+func (s *Session) ReserveTransfer(user *acl.User, upload bool) (func(), error) {
+	server := s.server
+
+	max := user.Downloads
+	if upload {
+		max = user.Uploads
+	}
+
+	if max == 0 && len(user.PrimaryGroup) > 0 {
+		if g, err := server.auth.GetGroup(user.PrimaryGroup); err == nil {
+			max = g.Downloads
+			if upload {
+				max = g.Uploads
+			}
+		}
+	}
+
+	slots, errFull := server.downloads, ErrTooManyDownloads
+	if upload {
+		slots, errFull = server.uploads, ErrTooManyUploads
+	}
+
+	name := strings.ToLower(user.Name)
+
+	server.transfersMtx.Lock()
+	defer server.transfersMtx.Unlock()
+
+	if max > 0 && slots[name] >= max {
+		return nil, errFull
+	}
+
+	slots[name]++
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			server.transfersMtx.Lock()
+			defer server.transfersMtx.Unlock()
+
+			slots[name]--
+			if slots[name] <= 0 {
+				delete(slots, name)
+			}
+		})
+	}, nil
+}
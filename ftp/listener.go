@@ -0,0 +1,202 @@
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ListenerOpts describes one of the addresses the server accepts control
+// connections on. Each listener has its own rules, so a site can have one
+// for an internal network and another only reachable through bouncers
+type ListenerOpts struct {
+	Name string
+
+	Host string `goftpd:"host"`
+	Port int    `goftpd:"port"`
+
+	// the control connection is TLS from the first byte and PROT P is
+	// assumed
+	ImplicitTLS bool `goftpd:"implicit_tls"`
+
+	// allow USER and PASS without AUTH TLS first
+	AllowPlaintext bool `goftpd:"allow_plaintext"`
+
+	// when set only connections from these IPs or CIDRs are accepted, the
+	// rest are closed without a reply
+	Allow []string `goftpd:"allow"`
+
+	// connections from these IPs or CIDRs must start with a PROXY protocol
+	// v1 or v2 header, the addresses in it are used for the session
+	ProxyFrom []string `goftpd:"proxy_from"`
+
+	// bouncers at these IPs or CIDRs can send IDNT with the client's ident
+	// and address
+	IdntFrom []string `goftpd:"idnt_from"`
+
+	PassivePorts []int  `goftpd:"passive_ports"`
+	PublicIP     string `goftpd:"public_ip"`
+	BindIP       string `goftpd:"bind_ip"`
+}
+
+// listener holds the state for a ListenerOpts
+type listener struct {
+	*ListenerOpts
+
+	allow     []*net.IPNet
+	proxyFrom []*net.IPNet
+	idntFrom  []*net.IPNet
+
+	passivePortsMax *big.Int
+	passivePorts    map[int64]struct{}
+	passivePortsMtx sync.Mutex
+}
+
+func newListener(opts *ListenerOpts) (*listener, error) {
+	if len(opts.PassivePorts) != 2 || opts.PassivePorts[0] >= opts.PassivePorts[1] {
+		return nil, fmt.Errorf("listener '%s': passive ports must be in order: min,max", opts.Name)
+	}
+
+	l := listener{
+		ListenerOpts:    opts,
+		passivePorts:    make(map[int64]struct{}, 0),
+		passivePortsMax: big.NewInt(int64(opts.PassivePorts[1] - opts.PassivePorts[0])),
+	}
+
+	var err error
+
+	l.allow, err = parseIPNets(opts.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("listener '%s': allow: %w", opts.Name, err)
+	}
+
+	l.proxyFrom, err = parseIPNets(opts.ProxyFrom)
+	if err != nil {
+		return nil, fmt.Errorf("listener '%s': proxy_from: %w", opts.Name, err)
+	}
+
+	l.idntFrom, err = parseIPNets(opts.IdntFrom)
+	if err != nil {
+		return nil, fmt.Errorf("listener '%s': idnt_from: %w", opts.Name, err)
+	}
+
+	return &l, nil
+}
+
+// parseIPNets parses a list of IPs and CIDRs, a single address is the same
+// as a /32 or /128
+func parseIPNets(list []string) ([]*net.IPNet, error) {
+	var ipnets []*net.IPNet
+
+	for _, a := range list {
+		if !strings.Contains(a, "/") {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return nil, fmt.Errorf("bad address '%s'", a)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+
+			ipnets = append(ipnets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, fmt.Errorf("bad address '%s': %w", a, err)
+		}
+
+		ipnets = append(ipnets, ipnet)
+	}
+
+	return ipnets, nil
+}
+
+// containsIP checks to see if the connection comes from one of ipnets
+func containsIP(ipnets []*net.IPNet, conn net.Conn) bool {
+	ip := remoteIP(conn)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipnet := range ipnets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowed checks the connection against the allow list, everyone is allowed
+// if there isn't one
+func (l *listener) allowed(conn net.Conn) bool {
+	if len(l.allow) == 0 {
+		return true
+	}
+
+	return containsIP(l.allow, conn)
+}
+
+// proxied checks to see if the connection is from a trusted proxy, which
+// has to start with a PROXY protocol header
+func (l *listener) proxied(conn net.Conn) bool {
+	return containsIP(l.proxyFrom, conn)
+}
+
+// listen accepts connections until the context is cancelled
+func (l *listener) listen(ctx context.Context, server *Server) error {
+	addr := net.JoinHostPort(l.Host, fmt.Sprintf("%d", l.Port))
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	// closing the listener stops Accept
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-server.stop:
+		}
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+
+			// check if this is a cancellation or shutdown
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-server.stop:
+				return nil
+			default:
+			}
+
+			// check if this is temporary
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+
+			return err
+		}
+
+		if !l.allowed(conn) {
+			conn.Close()
+			continue
+		}
+
+		// connection limits are checked by the session so it can reply
+		go server.handleConnection(ctx, l, conn)
+	}
+}
@@ -0,0 +1,88 @@
+package ftp
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// list runs a listing command and returns what was sent over the data
+// connection
+func (c *testClient) list(format string, args ...interface{}) string {
+	c.t.Helper()
+
+	data := c.pasv()
+	c.cmd(150, format, args...)
+
+	got, err := ioutil.ReadAll(data)
+	if err != nil {
+		c.t.Fatalf("unexpected error reading data: %s", err)
+	}
+	c.expect(226)
+
+	return string(got)
+}
+
+func TestListingDotfiles(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/dir/.message", []byte("hi"))
+	ts.createFile(t, "/dir/a.txt", []byte("a"))
+
+	c := ts.login(t, "alice")
+
+	for _, command := range []string{"NLST dir", "NLST -a dir", "LIST dir"} {
+		if got := c.list(command); !strings.Contains(got, ".message") {
+			t.Errorf("expected .message in %s got %q", command, got)
+		}
+	}
+}
+
+func TestListingGlob(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/a.txt", []byte("a"))
+	ts.createFile(t, "/b.txt", []byte("b"))
+	ts.createFile(t, "/c.nfo", []byte("c"))
+	ts.createFile(t, "/Foo[2020]/d.txt", []byte("d"))
+
+	c := ts.login(t, "alice")
+
+	got := c.list("NLST *.txt")
+	if !strings.Contains(got, "a.txt") || !strings.Contains(got, "b.txt") || strings.Contains(got, "c.nfo") {
+		t.Errorf("expected only the .txt files got %q", got)
+	}
+
+	// matches nothing as a pattern, so is listed as it is
+	if got := c.list("NLST Foo[2020]"); !strings.Contains(got, "d.txt") {
+		t.Errorf("expected d.txt got %q", got)
+	}
+
+	c.pasv()
+	c.cmd(451, "NLST *.zip")
+}
+
+func TestListingRecursiveDepth(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	// deeper than the 8 levels -R lists
+	path := strings.Repeat("/d", 12)
+	ts.createFile(t, path+"/deep.txt", []byte("deep"))
+
+	c := ts.login(t, "alice")
+
+	got := c.list("NLST -R")
+
+	var headers int
+	for _, line := range strings.Split(got, "\n") {
+		if strings.HasSuffix(strings.TrimRight(line, "\r"), ":") {
+			headers++
+		}
+	}
+
+	if headers != 8 {
+		t.Errorf("expected 8 directories listed got %d in %q", headers, got)
+	}
+
+	if strings.Contains(got, "deep.txt") {
+		t.Errorf("expected the listing to stop before deep.txt got %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+package ftp
+
+import (
+	"testing"
+)
+
+func TestPRETSTOR(t *testing.T) {
+	// can resume but not overwrite
+	ts := newTestServerWithRules(t, []string{
+		"download /** *",
+		"upload /** *",
+		"resume /** *",
+	}, nil)
+	ts.createFile(t, "/a.txt", []byte("HELLO"))
+
+	c := ts.login(t, "alice")
+
+	c.cmd(550, "PRET STOR a.txt")
+	c.cmd(200, "PRET STOR b.txt")
+	c.cmd(200, "PRET APPE a.txt")
+
+	c.cmd(350, "REST 5")
+	c.cmd(200, "PRET STOR a.txt")
+	c.cmd(350, "REST 0")
+
+	c.cmd(350, "RANG 0 1")
+	c.cmd(200, "PRET STOR a.txt")
+}
+
+func TestPRETAnnouncement(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/a.txt", []byte("HELLO"))
+
+	c := ts.login(t, "alice")
+
+	c.cmd(250, "CWD /")
+	c.cmd(200, "PRET RETR a.txt")
+
+	// the session goroutine has finished with PRET once NOOP is answered,
+	// and takes the registry lock for each command
+	c.cmd(200, "NOOP")
+
+	session, _ := ts.entry(t, "alice")
+
+	ts.sessionsMtx.Lock()
+	command, path := session.PRET()
+	ts.sessionsMtx.Unlock()
+
+	if command != "RETR" || path != "/a.txt" {
+		t.Fatalf("expected RETR /a.txt got %s %s", command, path)
+	}
+
+	// cleared once the transfer starts
+	data := c.pasv()
+	c.cmd(150, "RETR a.txt")
+	data.Close()
+	c.read()
+	c.cmd(200, "NOOP")
+
+	ts.sessionsMtx.Lock()
+	command, path = session.PRET()
+	ts.sessionsMtx.Unlock()
+
+	if len(command) > 0 || len(path) > 0 {
+		t.Fatalf("expected PRET to be cleared got %s %s", command, path)
+	}
+}
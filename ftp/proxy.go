@@ -0,0 +1,229 @@
+package ftp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyHeaderTimeout is how long a trusted proxy has to send the header
+const proxyHeaderTimeout = time.Second * 10
+
+// the longest a v1 header can be, including the CRLF
+const proxyV1MaxLength = 107
+
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+var ErrProxyHeader = errors.New("bad PROXY protocol header")
+
+// proxyConn is a connection that came through a proxy, the addresses are
+// those of the client and the address it connected to. Anything read past
+// the header is kept in reader
+type proxyConn struct {
+	net.Conn
+
+	reader *bufio.Reader
+
+	local  net.Addr
+	remote net.Addr
+}
+
+// Read implements the io.Reader interface
+func (c *proxyConn) Read(p []byte) (int, error) { return c.reader.Read(p) }
+
+func (c *proxyConn) LocalAddr() net.Addr  { return c.local }
+func (c *proxyConn) RemoteAddr() net.Addr { return c.remote }
+
+// readProxyHeader reads a PROXY protocol v1 or v2 header from the start of
+// the connection. A header for a local connection, or one for a protocol we
+// don't know, keeps the connection's own addresses
+func readProxyHeader(conn net.Conn) (net.Conn, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout)); err != nil {
+		return nil, err
+	}
+
+	pc := proxyConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+		local:  conn.LocalAddr(),
+		remote: conn.RemoteAddr(),
+	}
+
+	// a v1 header is always longer than the v2 signature
+	start, err := pc.reader.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(start, proxyV2Signature):
+		err = pc.readV2()
+
+	case bytes.HasPrefix(start, proxyV1Prefix):
+		err = pc.readV1()
+
+	default:
+		err = ErrProxyHeader
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return &pc, nil
+}
+
+// readV1 parses the text header
+//
+//	PROXY TCP4 192.0.2.1 198.51.100.1 56324 21\r\n
+//	PROXY UNKNOWN\r\n
+func (pc *proxyConn) readV1() error {
+	var line []byte
+
+	for {
+		b, err := pc.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+
+		if len(line) >= proxyV1MaxLength {
+			return ErrProxyHeader
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return ErrProxyHeader
+	}
+
+	fields := strings.Fields(string(line))
+
+	if len(fields) < 2 {
+		return ErrProxyHeader
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil
+
+	case "TCP4", "TCP6":
+		if len(fields) != 6 {
+			return ErrProxyHeader
+		}
+
+	default:
+		return ErrProxyHeader
+	}
+
+	src, err := proxyV1Addr(fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+
+	dst, err := proxyV1Addr(fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+
+	pc.remote, pc.local = src, dst
+
+	return nil
+}
+
+func proxyV1Addr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, ErrProxyHeader
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrProxyHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// readV2 parses the binary header, the 12 byte signature is followed by the
+// version and command, the address family and protocol, and the length of
+// the addresses that follow
+func (pc *proxyConn) readV2() error {
+	header := make([]byte, len(proxyV2Signature)+4)
+	if _, err := io.ReadFull(pc.reader, header); err != nil {
+		return err
+	}
+
+	versionCommand := header[12]
+	family := header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+
+	if versionCommand>>4 != 2 {
+		return fmt.Errorf("%w: unsupported version %d", ErrProxyHeader, versionCommand>>4)
+	}
+
+	addrs := make([]byte, length)
+	if _, err := io.ReadFull(pc.reader, addrs); err != nil {
+		return err
+	}
+
+	switch versionCommand & 0x0f {
+	// LOCAL, health checks from the proxy itself
+	case 0x00:
+		return nil
+
+	// PROXY
+	case 0x01:
+
+	default:
+		return ErrProxyHeader
+	}
+
+	var size int
+	switch family {
+	// TCP over IPv4
+	case 0x11:
+		size = net.IPv4len
+
+	// TCP over IPv6
+	case 0x21:
+		size = net.IPv6len
+
+	default:
+		return nil
+	}
+
+	if len(addrs) < size*2+4 {
+		return ErrProxyHeader
+	}
+
+	pc.remote = &net.TCPAddr{
+		IP:   net.IP(addrs[:size]),
+		Port: int(binary.BigEndian.Uint16(addrs[size*2:])),
+	}
+
+	pc.local = &net.TCPAddr{
+		IP:   net.IP(addrs[size : size*2]),
+		Port: int(binary.BigEndian.Uint16(addrs[size*2+2:])),
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package ftp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// proxyV2Header builds a v2 header with the command, family and address block
+func proxyV2Header(command, family byte, addrs []byte) []byte {
+	var b bytes.Buffer
+
+	b.Write(proxyV2Signature)
+	b.WriteByte(0x20 | command)
+	b.WriteByte(family)
+	binary.Write(&b, binary.BigEndian, uint16(len(addrs)))
+	b.Write(addrs)
+
+	return b.Bytes()
+}
+
+// proxyV2Addrs builds the address block, source then destination
+func proxyV2Addrs(src, dst string, srcPort, dstPort uint16) []byte {
+	var b bytes.Buffer
+
+	srcIP, dstIP := net.ParseIP(src), net.ParseIP(dst)
+	if ip := srcIP.To4(); ip != nil {
+		srcIP, dstIP = ip, dstIP.To4()
+	}
+
+	b.Write(srcIP)
+	b.Write(dstIP)
+	binary.Write(&b, binary.BigEndian, srcPort)
+	binary.Write(&b, binary.BigEndian, dstPort)
+
+	return b.Bytes()
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	var tests = []struct {
+		name   string
+		header []byte
+		remote string
+		local  string
+		err    bool
+	}{
+		{
+			"v1 tcp4",
+			[]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 21\r\n"),
+			"192.0.2.1:56324",
+			"198.51.100.1:21",
+			false,
+		},
+		{
+			"v1 tcp6",
+			[]byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 21\r\n"),
+			"[2001:db8::1]:56324",
+			"[2001:db8::2]:21",
+			false,
+		},
+		{
+			"v1 unknown",
+			[]byte("PROXY UNKNOWN\r\n"),
+			"pipe",
+			"pipe",
+			false,
+		},
+		{
+			"v1 too long",
+			[]byte("PROXY TCP4 " + strings.Repeat("1", proxyV1MaxLength)),
+			"",
+			"",
+			true,
+		},
+		{
+			"v1 bad port",
+			[]byte("PROXY TCP4 192.0.2.1 198.51.100.1 65536 21\r\n"),
+			"",
+			"",
+			true,
+		},
+		{
+			"v1 bad address",
+			[]byte("PROXY TCP4 192.0.2 198.51.100.1 56324 21\r\n"),
+			"",
+			"",
+			true,
+		},
+		{
+			"v1 no cr",
+			[]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 21\n"),
+			"",
+			"",
+			true,
+		},
+		{
+			"v2 proxy ipv4",
+			proxyV2Header(0x01, 0x11, proxyV2Addrs("192.0.2.1", "198.51.100.1", 56324, 21)),
+			"192.0.2.1:56324",
+			"198.51.100.1:21",
+			false,
+		},
+		{
+			"v2 proxy ipv6",
+			proxyV2Header(0x01, 0x21, proxyV2Addrs("2001:db8::1", "2001:db8::2", 56324, 21)),
+			"[2001:db8::1]:56324",
+			"[2001:db8::2]:21",
+			false,
+		},
+		{
+			"v2 local",
+			proxyV2Header(0x00, 0x11, proxyV2Addrs("192.0.2.1", "198.51.100.1", 56324, 21)),
+			"pipe",
+			"pipe",
+			false,
+		},
+		{
+			"v2 short address block",
+			proxyV2Header(0x01, 0x21, proxyV2Addrs("192.0.2.1", "198.51.100.1", 56324, 21)),
+			"",
+			"",
+			true,
+		},
+		{
+			"v2 bad command",
+			proxyV2Header(0x02, 0x11, proxyV2Addrs("192.0.2.1", "198.51.100.1", 56324, 21)),
+			"",
+			"",
+			true,
+		},
+		{
+			"bad signature",
+			[]byte("\r\n\r\n\x00\r\nQUIT!\x21\x11\x00\x00"),
+			"",
+			"",
+			true,
+		},
+		{
+			"no header",
+			[]byte("USER alice\r\n"),
+			"",
+			"",
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer server.Close()
+			defer client.Close()
+
+			// anything after the header belongs to the client
+			payload := append(append([]byte{}, tt.header...), "USER alice\r\n"...)
+			go client.Write(payload)
+
+			conn, err := readProxyHeader(server)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error got remote %s", conn.RemoteAddr())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := conn.RemoteAddr().String(); got != tt.remote {
+				t.Errorf("expected remote %s got %s", tt.remote, got)
+			}
+
+			if got := conn.LocalAddr().String(); got != tt.local {
+				t.Errorf("expected local %s got %s", tt.local, got)
+			}
+
+			buf := make([]byte, len("USER alice\r\n"))
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				t.Fatalf("unexpected error reading past the header: %s", err)
+			}
+
+			if string(buf) != "USER alice\r\n" {
+				t.Errorf("expected USER alice got %q", buf)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package ftp
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRangeRETR(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/a.txt", []byte("HELLO WORLD"))
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "TYPE I")
+
+	var tests = []struct {
+		start, end int
+		expected   string
+	}{
+		{0, 4, "HELLO"},
+		{6, 10, "WORLD"},
+		{6, 100, "WORLD"},
+		{1, 0, "HELLO WORLD"},
+	}
+
+	for _, tt := range tests {
+		c.cmd(350, "RANG %d %d", tt.start, tt.end)
+
+		data := c.pasv()
+		c.cmd(150, "RETR a.txt")
+
+		got, err := ioutil.ReadAll(data)
+		if err != nil {
+			t.Fatalf("unexpected error reading data: %s", err)
+		}
+		c.expect(226)
+
+		if string(got) != tt.expected {
+			t.Errorf("RANG %d %d expected %q got %q", tt.start, tt.end, tt.expected, got)
+		}
+	}
+
+	// the range is reset once used
+	data := c.pasv()
+	c.cmd(150, "RETR a.txt")
+	if got, _ := ioutil.ReadAll(data); string(got) != "HELLO WORLD" {
+		t.Errorf("expected the whole file got %q", got)
+	}
+	c.expect(226)
+
+	c.cmd(350, "RANG 11 20")
+	c.pasv()
+	c.cmd(550, "RETR a.txt")
+}
+
+func TestRangeSTOR(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/a.txt", []byte("HELLO WORLD"))
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "TYPE I")
+
+	c.cmd(350, "RANG 6 10")
+
+	data := c.pasv()
+	c.cmd(150, "STOR a.txt")
+
+	// past the end of the range is ignored
+	fmt.Fprint(data, "THEREXXX")
+	data.Close()
+	c.expect(226)
+
+	f, err := ts.mem.Open("/a.txt")
+	if err != nil {
+		t.Fatalf("unexpected error opening file: %s", err)
+	}
+	defer f.Close()
+
+	got, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error reading file: %s", err)
+	}
+
+	if expected := "HELLO THERE"; string(got) != expected {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestRangeHASH(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/a.txt", []byte("HELLO WORLD"))
+	ts.createFile(t, "/empty.txt", nil)
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "OPTS HASH CRC32")
+
+	var tests = []struct {
+		rang     string
+		path     string
+		expected string
+	}{
+		{"", "a.txt", fmt.Sprintf("CRC32 0-11 %08x", crc32.ChecksumIEEE([]byte("HELLO WORLD")))},
+		{"RANG 0 4", "a.txt", fmt.Sprintf("CRC32 0-5 %08x", crc32.ChecksumIEEE([]byte("HELLO")))},
+		// the end is clamped to the end of the file
+		{"RANG 6 100", "a.txt", fmt.Sprintf("CRC32 6-11 %08x", crc32.ChecksumIEEE([]byte("WORLD")))},
+		{"", "empty.txt", "CRC32 0-0 00000000"},
+		{"RANG 0 10", "empty.txt", "CRC32 0-0 00000000"},
+	}
+
+	for _, tt := range tests {
+		if len(tt.rang) > 0 {
+			c.cmd(350, tt.rang)
+		}
+
+		if msg := c.cmd(213, "HASH %s", tt.path); !strings.Contains(msg, tt.expected) {
+			t.Errorf("expected %q in %q", tt.expected, msg)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package ftp
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+var (
+	ErrSessionNotFound = errors.New("no session with that id")
+	ErrOwnSession      = errors.New("that is your own session, use QUIT")
+)
+
+// updateEntry records what the session is doing for SITE WHO, an empty
+// command keeps the last one. Only called from the control goroutine
+func (s *Session) updateEntry(command string) {
+	addr := s.RemoteAddr().String()
+
+	s.server.sessionsMtx.Lock()
+	defer s.server.sessionsMtx.Unlock()
+
+	entry, ok := s.server.sessions[s]
+	if !ok {
+		return
+	}
+
+	entry.addr = addr
+	entry.ident = s.ident
+	entry.cwd = s.currentDir
+
+	if len(command) > 0 {
+		entry.command = command
+	}
+}
+
+// ID is the session's id in the registry, used by SITE KILL
+func (s *Session) ID() uint64 {
+	s.server.sessionsMtx.Lock()
+	defer s.server.sessionsMtx.Unlock()
+
+	if entry, ok := s.server.sessions[s]; ok {
+		return entry.id
+	}
+
+	return 0
+}
+
+// Sessions returns every connected session, oldest first
+func (s *Session) Sessions() []cmd.SessionInfo {
+	server := s.server
+
+	server.sessionsMtx.Lock()
+	defer server.sessionsMtx.Unlock()
+
+	sessions := make([]cmd.SessionInfo, 0, len(server.sessions))
+
+	for other, e := range server.sessions {
+		info := cmd.SessionInfo{
+			ID:         e.id,
+			User:       e.user,
+			Group:      e.group,
+			RemoteAddr: e.addr,
+			Ident:      e.ident,
+			Command:    e.command,
+			CWD:        e.cwd,
+			Connected:  e.connected,
+			Idle:       other.idle(),
+		}
+
+		if t := other.currentTransfer(); t != nil {
+			info.Command = t.command
+			info.Path = t.path
+
+			switch t.command {
+			case "RETR":
+				info.Direction = "download"
+				info.Bytes = int64(t.conn.WireBytesWritten())
+			case "STOR", "APPE":
+				info.Direction = "upload"
+				info.Bytes = int64(t.conn.WireBytesRead())
+			default:
+				info.Direction = "list"
+				info.Bytes = int64(t.conn.WireBytesWritten())
+			}
+
+			if elapsed := time.Since(t.started).Seconds(); elapsed > 0 {
+				info.Speed = float64(info.Bytes) / elapsed
+			}
+		}
+
+		sessions = append(sessions, info)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+
+	return sessions
+}
+
+// KickUser disconnects every session logged in as user apart from this one,
+// returning how many there were
+func (s *Session) KickUser(user, reason string) int {
+	server := s.server
+	name := strings.ToLower(user)
+
+	var targets []killTarget
+
+	server.sessionsMtx.Lock()
+
+	for other, e := range server.sessions {
+		if other == s || e.user != name || e.killed {
+			continue
+		}
+
+		targets = append(targets, markKilled(other, e, other.currentTransfer()))
+	}
+
+	server.sessionsMtx.Unlock()
+
+	for _, target := range targets {
+		target.kill(reason)
+	}
+
+	return len(targets)
+}
+
+// KillSession disconnects the session with the id, which can't be this one
+func (s *Session) KillSession(id uint64, reason string) error {
+	server := s.server
+
+	server.sessionsMtx.Lock()
+
+	for other, e := range server.sessions {
+		if e.id != id {
+			continue
+		}
+
+		if other == s {
+			server.sessionsMtx.Unlock()
+			return ErrOwnSession
+		}
+
+		// already on its way out
+		if e.killed {
+			server.sessionsMtx.Unlock()
+			return nil
+		}
+
+		target := markKilled(other, e, other.currentTransfer())
+
+		server.sessionsMtx.Unlock()
+
+		target.kill(reason)
+
+		return nil
+	}
+
+	server.sessionsMtx.Unlock()
+
+	return ErrSessionNotFound
+}
+
+// killTarget is a session picked to be disconnected, along with its id and
+// any transfer at the time
+type killTarget struct {
+	session *Session
+	id      uint64
+	t       *transfer
+}
+
+// markKilled marks the entry so the session isn't picked again. Called with
+// sessionsMtx held
+func markKilled(s *Session, entry *sessionEntry, t *transfer) killTarget {
+	entry.killed = true
+
+	return killTarget{
+		session: s,
+		id:      entry.id,
+		t:       t,
+	}
+}
+
+// kill disconnects the session and aborts any transfer it had running.
+// Called without sessionsMtx held, the session may have ended and been
+// reused since, in which case its id won't match and it is left alone
+func (k killTarget) kill(reason string) {
+	k.session.disconnectIf(k.id, reason)
+
+	// the control goroutine could be waiting on the transfer
+	if k.t != nil {
+		go k.t.Abort()
+	}
+}
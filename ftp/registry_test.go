@@ -0,0 +1,170 @@
+package ftp
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+// newSiteTestServer allows everyone the registry's SITE commands
+func newSiteTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	return newTestServer(t, func(opts *ServerOpts) {
+		for _, command := range []string{"WHO", "SWHO", "KICK", "KILL"} {
+			a, err := acl.NewFromString("*")
+			if err != nil {
+				t.Fatalf("unexpected error creating acl: %s", err)
+			}
+			opts.SetSiteACL(command, a)
+		}
+	})
+}
+
+// sessionID finds the user's id in the SITE SWHO reply
+func sessionID(t *testing.T, swho, user string) uint64 {
+	t.Helper()
+
+	for _, line := range strings.Split(swho, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[1] != user {
+			continue
+		}
+
+		id, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			t.Fatalf("unexpected error parsing id from %q: %s", line, err)
+		}
+
+		return id
+	}
+
+	t.Fatalf("expected %s in %q", user, swho)
+
+	return 0
+}
+
+// entry returns the session and registry entry for the user
+func (ts *testServer) entry(t *testing.T, user string) (*Session, sessionEntry) {
+	t.Helper()
+
+	ts.sessionsMtx.Lock()
+	defer ts.sessionsMtx.Unlock()
+
+	for s, e := range ts.sessions {
+		if e.user == user {
+			return s, *e
+		}
+	}
+
+	t.Fatalf("expected a session for %s", user)
+
+	return nil, sessionEntry{}
+}
+
+func TestSiteWHO(t *testing.T) {
+	ts := newSiteTestServer(t)
+
+	alice := ts.login(t, "alice")
+	ts.login(t, "bob")
+
+	// not logged in, so only in SWHO
+	ts.dial(t)
+
+	msg := alice.cmd(200, "SITE WHO")
+
+	for _, expected := range []string{" alice ", " bob ", "2 user(s) online."} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("expected %q in %q", expected, msg)
+		}
+	}
+
+	msg = alice.cmd(200, "SITE SWHO")
+
+	for _, expected := range []string{" alice ", " bob ", "3 session(s) connected."} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("expected %q in %q", expected, msg)
+		}
+	}
+
+	if id := sessionID(t, msg, "alice"); id == 0 {
+		t.Errorf("expected an id for alice in %q", msg)
+	}
+}
+
+func TestSiteKICK(t *testing.T) {
+	ts := newSiteTestServer(t)
+
+	alice := ts.login(t, "alice")
+	bob := ts.login(t, "bob")
+	other := ts.login(t, "bob")
+
+	alice.cmd(501, "SITE KICK")
+	alice.cmd(550, "SITE KICK carol")
+
+	msg := alice.cmd(200, "SITE KICK BOB")
+	if !strings.Contains(msg, "Kicked 2 session(s)") {
+		t.Errorf("expected both sessions kicked in %q", msg)
+	}
+
+	bob.expect(421)
+	other.expect(421)
+
+	// they are only kicked once, even before they have gone
+	alice.cmd(550, "SITE KICK bob")
+
+	// never yourself
+	alice.cmd(550, "SITE KICK alice")
+	alice.cmd(200, "NOOP")
+}
+
+func TestSiteKILL(t *testing.T) {
+	ts := newSiteTestServer(t)
+
+	alice := ts.login(t, "alice")
+	bob := ts.login(t, "bob")
+
+	msg := alice.cmd(200, "SITE SWHO")
+
+	alice.cmd(501, "SITE KILL abc")
+	alice.cmd(550, "SITE KILL 9999")
+	alice.cmd(550, "SITE KILL %d", sessionID(t, msg, "alice"))
+
+	alice.cmd(200, "SITE KILL %d", sessionID(t, msg, "bob"))
+	bob.expect(421)
+
+	alice.cmd(200, "NOOP")
+}
+
+func TestSitePermissionDenied(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	alice := ts.login(t, "alice")
+	bob := ts.login(t, "bob")
+
+	for _, command := range []string{"WHO", "SWHO", "KICK bob", "KILL 2"} {
+		msg := alice.cmd(550, "SITE %s", command)
+		if !strings.Contains(msg, "Permission denied") {
+			t.Errorf("expected permission denied for %s got %q", command, msg)
+		}
+	}
+
+	bob.cmd(200, "NOOP")
+}
+
+func TestDisconnectIfStaleID(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	bob := ts.login(t, "bob")
+
+	session, e := ts.entry(t, "bob")
+
+	// the id of a session that has since ended
+	session.disconnectIf(e.id+1, "Stale.")
+	bob.cmd(200, "NOOP")
+
+	session.disconnectIf(e.id, "Gone.")
+	bob.expect(421)
+}
@@ -0,0 +1,104 @@
+package ftp
+
+import (
+	"github.com/goftpd/goftpd/script"
+)
+
+// options returns the current ServerOpts, they can be replaced by Reload so
+// shouldn't be kept hold of
+func (s *Server) options() *ServerOpts {
+	s.optsMtx.RLock()
+	defer s.optsMtx.RUnlock()
+	return s.opts
+}
+
+// scripts returns the current script.Engine
+func (s *Server) scripts() script.Engine {
+	s.optsMtx.RLock()
+	defer s.optsMtx.RUnlock()
+	return s.se
+}
+
+// Reload replaces the options and script engine, sessions use them from
+// their next command. Only limits, speeds, timeouts, the site name and the
+// TLS certificate change, the listeners and passive ports need a restart so
+// are kept as they are. Nothing changes if an error is returned
+func (s *Server) Reload(opts *ServerOpts, se script.Engine) error {
+	current := s.options()
+
+	reloaded := *opts
+
+	reloaded.Host = current.Host
+	reloaded.Port = current.Port
+	reloaded.ImplicitTLSPort = current.ImplicitTLSPort
+	reloaded.ProxyFrom = current.ProxyFrom
+	reloaded.IdntFrom = current.IdntFrom
+	reloaded.Listeners = current.Listeners
+	reloaded.PassivePorts = current.PassivePorts
+	reloaded.PublicIP = current.PublicIP
+	reloaded.BindIP = current.BindIP
+
+	return s.setOptions(&reloaded, se)
+}
+
+// setOptions swaps in opts and se along with the global speed limits and
+// the xferlog, which is only reopened if its path has changed
+func (s *Server) setOptions(opts *ServerOpts, se script.Engine) error {
+	var pathSpeeds []*pathSpeed
+
+	for _, po := range opts.PathSpeeds {
+		ps, err := newPathSpeed(po)
+		if err != nil {
+			return err
+		}
+		pathSpeeds = append(pathSpeeds, ps)
+	}
+
+	current := s.transferLog()
+
+	xl := current
+	if xl == nil || xl.path != opts.XferLog {
+		xl = nil
+
+		if len(opts.XferLog) > 0 {
+			var err error
+			if xl, err = openXferLog(opts.XferLog); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.optsMtx.Lock()
+	defer s.optsMtx.Unlock()
+
+	s.opts = opts
+	s.se = se
+	s.pathSpeeds = pathSpeeds
+
+	// keep the buckets so running transfers see the new limit
+	s.downloadBucket = updateBucket(s.downloadBucket, opts.DownloadSpeed)
+	s.uploadBucket = updateBucket(s.uploadBucket, opts.UploadSpeed)
+
+	s.xferLog = xl
+	if current != nil && current != xl {
+		current.Close()
+	}
+
+	return nil
+}
+
+// updateBucket changes the limit of b, creating it if needed. Returns nil
+// when unlimited
+func updateBucket(b *bucket, kbps int) *bucket {
+	if kbps <= 0 {
+		return nil
+	}
+
+	if b == nil {
+		return newBucket(kbps)
+	}
+
+	b.setRate(kbps)
+
+	return b
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"math/big"
 	"net"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/goftpd/goftpd/acl"
 	"github.com/goftpd/goftpd/script"
@@ -23,6 +25,61 @@ type ServerOpts struct {
 	Port         int    `goftpd:"port"`
 	PassivePorts []int  `goftpd:"passive_ports"`
 
+	// optional port where the control connection is TLS from the start
+	ImplicitTLSPort int `goftpd:"implicit_tls_port"`
+
+	// trusted proxies and bouncers for the listeners on Port and
+	// ImplicitTLSPort, see ListenerOpts
+	ProxyFrom []string `goftpd:"proxy_from"`
+	IdntFrom  []string `goftpd:"idnt_from"`
+
+	// connection limits, 0 is unlimited. max_sessions_exempt is extra room
+	// over max_sessions that only limits_exempt users can log in with
+	MaxSessions       int    `goftpd:"max_sessions"`
+	MaxSessionsExempt int    `goftpd:"max_sessions_exempt"`
+	MaxSessionsPerIP  int    `goftpd:"max_sessions_per_ip"`
+	MaxLoginsPerUser  int    `goftpd:"max_logins_per_user"`
+	MaxLoginsPerGroup int    `goftpd:"max_logins_per_group"`
+	LimitsExempt      string `goftpd:"limits_exempt"`
+	limitsExempt      *acl.ACL
+
+	// who can use the native SITE commands, nobody when unset
+	SiteWho  string `goftpd:"site_who"`
+	SiteSWho string `goftpd:"site_swho"`
+	SiteKick string `goftpd:"site_kick"`
+	SiteKill string `goftpd:"site_kill"`
+	SiteIdle string `goftpd:"site_idle"`
+	siteACLs map[string]*acl.ACL
+
+	// speed limits in KB/s, 0 is unlimited. download_speed and upload_speed
+	// are shared by everyone, user_* is the default for users without
+	// their own
+	DownloadSpeed     int `goftpd:"download_speed"`
+	UploadSpeed       int `goftpd:"upload_speed"`
+	UserDownloadSpeed int `goftpd:"user_download_speed"`
+	UserUploadSpeed   int `goftpd:"user_upload_speed"`
+
+	// checked in order, the first path matching a transfer sets its limits
+	PathSpeeds []*PathSpeedOpts
+
+	// timeouts in seconds, 0 is forever. idle_timeout can be overridden
+	// for each user, stall_timeout ends a transfer that has sent or
+	// received nothing for that long
+	IdleTimeout        int `goftpd:"idle_timeout"`
+	LoginTimeout       int `goftpd:"login_timeout"`
+	DataAcceptTimeout  int `goftpd:"data_accept_timeout"`
+	DataConnectTimeout int `goftpd:"data_connect_timeout"`
+	StallTimeout       int `goftpd:"stall_timeout"`
+
+	// seconds Shutdown waits for running transfers to finish
+	ShutdownTimeout int `goftpd:"shutdown_timeout"`
+
+	// optional file RETR, STOR and APPE are logged to in xferlog format
+	XferLog string `goftpd:"xferlog"`
+
+	// when empty Host, Port and ImplicitTLSPort are used
+	Listeners []*ListenerOpts
+
 	PublicIP string `goftpd:"public_ip"`
 	BindIP   string `goftpd:"bind_ip"`
 
@@ -33,21 +90,95 @@ type ServerOpts struct {
 
 func (o *ServerOpts) SetTLSConfig(t *tls.Config) { o.tlsConfig = t }
 
+func (o *ServerOpts) SetLimitsExempt(a *acl.ACL) { o.limitsExempt = a }
+
+// SetSiteACL sets who can use the native SITE command, e.g. WHO
+func (o *ServerOpts) SetSiteACL(command string, a *acl.ACL) {
+	if o.siteACLs == nil {
+		o.siteACLs = make(map[string]*acl.ACL, 0)
+	}
+	o.siteACLs[strings.ToUpper(command)] = a
+}
+
+// siteAllowed checks the user can use the native SITE command
+func (o *ServerOpts) siteAllowed(command string, user *acl.User) bool {
+	a, ok := o.siteACLs[command]
+	return ok && a.Match(user)
+}
+
+// defaultListeners are used when no listeners have been configured, one on
+// Port and an implicit TLS one on ImplicitTLSPort if it is set
+func (o *ServerOpts) defaultListeners() []*ListenerOpts {
+	listeners := []*ListenerOpts{
+		{
+			Name:         "default",
+			Host:         o.Host,
+			Port:         o.Port,
+			PassivePorts: o.PassivePorts,
+			PublicIP:     o.PublicIP,
+			BindIP:       o.BindIP,
+			ProxyFrom:    o.ProxyFrom,
+			IdntFrom:     o.IdntFrom,
+		},
+	}
+
+	if o.ImplicitTLSPort > 0 {
+		listeners = append(listeners, &ListenerOpts{
+			Name:         "implicit",
+			Host:         o.Host,
+			Port:         o.ImplicitTLSPort,
+			ImplicitTLS:  true,
+			PassivePorts: o.PassivePorts,
+			PublicIP:     o.PublicIP,
+			BindIP:       o.BindIP,
+			ProxyFrom:    o.ProxyFrom,
+			IdntFrom:     o.IdntFrom,
+		})
+	}
+
+	return listeners
+}
+
 // Server. Serves stuff.
 type Server struct {
-	*ServerOpts
-
 	fs vfs.VFS
 
 	auth acl.Authenticator
 
-	se script.Engine
+	// opts and se can be swapped by Reload, read them with options() and
+	// scripts()
+	opts    *ServerOpts
+	se      script.Engine
+	optsMtx sync.RWMutex
 
 	sessionPool sync.Pool
 
-	passivePortsMax *big.Int
-	passivePorts    map[int64]struct{}
-	passivePortsMtx sync.Mutex
+	listeners []*listener
+
+	// closed by Shutdown to stop accepting connections and commands
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	sessions      map[*Session]*sessionEntry
+	lastSessionID uint64
+	sessionsMtx   sync.Mutex
+
+	// running uploads and downloads by user
+	uploads      map[string]int
+	downloads    map[string]int
+	transfersMtx sync.Mutex
+
+	// bandwidth, the global buckets are nil when unlimited. Guarded by
+	// optsMtx as they are set from the options
+	downloadBucket *bucket
+	uploadBucket   *bucket
+	pathSpeeds     []*pathSpeed
+
+	// nil when xferlog isn't set, guarded by optsMtx
+	xferLog *xferLog
+
+	buckets    map[string]*bucket
+	bucketsMtx sync.Mutex
 }
 
 // NewServer returns a Server using the supplied ServerOpts and VFS. Will
@@ -56,108 +187,157 @@ type Server struct {
 func NewServer(opts *ServerOpts, fs vfs.VFS, auth acl.Authenticator, se script.Engine) (*Server, error) {
 
 	s := Server{
-		ServerOpts: opts,
-		fs:         fs,
-		auth:       auth,
-		se:         se,
+		fs:        fs,
+		auth:      auth,
+		sessions:  make(map[*Session]*sessionEntry, 0),
+		uploads:   make(map[string]int, 0),
+		downloads: make(map[string]int, 0),
+		buckets:   make(map[string]*bucket, 0),
+		stop:      make(chan struct{}),
 		sessionPool: sync.Pool{
 			New: func() interface{} {
 				return &Session{}
 			},
 		},
-		passivePorts:    make(map[int64]struct{}, 0),
-		passivePortsMax: big.NewInt(int64(opts.PassivePorts[1] - opts.PassivePorts[0])),
+	}
+
+	listenerOpts := opts.Listeners
+	if len(listenerOpts) == 0 {
+		listenerOpts = opts.defaultListeners()
+	}
+
+	for _, lo := range listenerOpts {
+		l, err := newListener(lo)
+		if err != nil {
+			return nil, err
+		}
+		s.listeners = append(s.listeners, l)
+	}
+
+	if err := s.setOptions(opts, se); err != nil {
+		return nil, err
 	}
 
 	return &s, nil
 }
 
 func (s *Server) TLSConfig() *tls.Config {
-	return s.tlsConfig
+	return s.options().tlsConfig
 }
 
-// ListenAndServe creates a new tcp listener on the configured Host and Port.
-// New connections are buffered down a channel before being given their own
-// goroutine. Takes a context and attemps to shutdown on cancellation/deadline
-func (s *Server) ListenAndServe(ctx context.Context) error {
-	ctx, cancel := context.WithCancel(ctx)
+// tlsClientConfig is used when we are the TLS client on a data connection,
+// the other end is another server's data port so there is nothing to verify
+// it against
+func (s *Server) tlsClientConfig() *tls.Config {
+	c := s.TLSConfig().Clone()
+	c.InsecureSkipVerify = true
+	return c
+}
 
-	addr := net.JoinHostPort(s.Host, fmt.Sprintf("%d", s.Port))
+// ListenAndServe starts accepting connections on each of the listeners.
+// Takes a context and attemps to shutdown on cancellation/deadline
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	errg, ctx := errgroup.WithContext(ctx)
 
-	l, err := net.Listen("tcp", addr)
-	if err != nil {
-		return err
+	for _, l := range s.listeners {
+		l := l
+		errg.Go(func() error {
+			return l.listen(ctx, s)
+		})
 	}
-	defer l.Close()
 
-	conns := make(chan net.Conn, 100)
+	return errg.Wait()
+}
 
-	var errg errgroup.Group
+// shuttingDown checks to see if Shutdown has been called
+func (s *Server) shuttingDown() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops accepting connections and disconnects every session with
+// a 421 once it isn't transferring anything. When the context is done any
+// sessions left are disconnected, aborting their transfers. Returns once
+// all sessions have ended
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
 
-	errg.Go(func() error {
-		for {
-			conn, err := l.Accept()
-			if err != nil {
+	ticker := time.NewTicker(time.Millisecond * 250)
+	defer ticker.Stop()
 
-				// check if this is a cancellation
-				select {
-				case <-ctx.Done():
-					return nil
-				default:
-				}
+	var err error
 
-				// check if this is temporary
-				if ne, ok := err.(net.Error); ok && ne.Temporary() {
-					continue
-				}
+	for {
+		s.sessionsMtx.Lock()
 
-				// fatal cancel ctx and return error
-				cancel()
+		if len(s.sessions) == 0 {
+			s.sessionsMtx.Unlock()
 
-				return err
+			if l := s.transferLog(); l != nil {
+				l.Close()
 			}
 
-			conns <- conn
+			return err
 		}
 
-		return nil
-	})
-
-	// TODO
-	// we can limit the total number of connections to the site here
-	errg.Go(func() error {
-		for {
-			select {
-			case c := <-conns:
-				go s.handleConnection(ctx, c)
-
-			case <-ctx.Done():
+		// sessions are only disconnected once, their control connection
+		// is closed after that
+		var targets []killTarget
 
-				// attempt to close the listener
-				if err := l.Close(); err != nil {
-					return err
-				}
+		for session, entry := range s.sessions {
+			if entry.killed {
+				continue
+			}
 
-				return nil
+			t := session.currentTransfer()
+			if t != nil && err == nil {
+				continue
 			}
+
+			targets = append(targets, markKilled(session, entry, t))
 		}
 
-		return nil
-	})
+		s.sessionsMtx.Unlock()
 
-	if err := errg.Wait(); err != nil {
-		return err
-	}
+		// disconnecting writes to the client, so isn't done holding the lock
+		for _, target := range targets {
+			target.kill(shutdownMessage)
+		}
 
-	return nil
+		// once the context is done its channel is always ready, so only
+		// the ticker is waited on after that
+		if err == nil {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+			case <-ticker.C:
+			}
+		} else {
+			<-ticker.C
+		}
+	}
 }
 
 // handleConnection takes a context and a tcp connection and attempts to
 // start a new session
-func (server *Server) handleConnection(ctx context.Context, conn net.Conn) {
+func (server *Server) handleConnection(ctx context.Context, l *listener, conn net.Conn) {
+	if l.proxied(conn) {
+		pc, err := readProxyHeader(conn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR proxy header from %s: %s\n", conn.RemoteAddr(), err)
+			conn.Close()
+			return
+		}
+		conn = pc
+	}
+
 	session := server.sessionPool.Get().(*Session)
 	session.Reset()
 	defer server.sessionPool.Put(session)
 
-	session.serve(ctx, server, conn)
+	session.serve(ctx, server, l, conn)
 }
@@ -0,0 +1,37 @@
+package ftp
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdownTimeout(t *testing.T) {
+	ts := newTestServer(t, func(opts *ServerOpts) {
+		opts.DownloadSpeed = 16
+	})
+	ts.createFile(t, "/big.bin", bytes.Repeat([]byte("x"), 1024*1024))
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "TYPE I")
+
+	c.pasv()
+	c.cmd(150, "RETR big.bin")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	started := time.Now()
+
+	if err := ts.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %s got %v", context.DeadlineExceeded, err)
+	}
+
+	// the transfer would take minutes to finish
+	if elapsed := time.Since(started); elapsed > time.Second*5 {
+		t.Fatalf("expected shutdown to abort the transfer, took %s", elapsed)
+	}
+
+	c.expect(421)
+}
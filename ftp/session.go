@@ -11,6 +11,8 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/goftpd/goftpd/acl"
 	"github.com/goftpd/goftpd/ftp/cmd"
@@ -23,39 +25,111 @@ import (
 type Session struct {
 	server *Server
 
+	// the listener the session connected through
+	listener *listener
+
 	active    bool
 	activeMtx sync.Mutex
 
-	control *Control
-	data    cmd.DataConn
+	control    *Control
+	controlMtx sync.Mutex
 
-	//
-	sbuilder strings.Builder
+	// the registry id, guarded by controlMtx so a session that has ended
+	// and been reused isn't disconnected by mistake, see disconnectIf
+	id uint64
+
+	data cmd.DataConn
+
+	// a command using the data connection running in the background
+	transfer    *transfer
+	transferMtx sync.Mutex
+
+	// replies for commands run on the control goroutine, transfers
+	// have their own
+	replyBuffer
 
 	// state
 	state           cmd.SessionState
 	dataProtected   bool
+	sscn            bool
 	binaryMode      bool
+	transferMode    cmd.TransferMode
+	deflateLevel    int
+	epsvAll         bool
+	mlstFacts       []string
+	hashAlgorithm   string
+	rangeStart      int64
+	rangeEnd        int64
 	lastCommand     string
+	lastCommandMtx  sync.Mutex
+	lastTransfer    *cmd.TransferStats
+	lastTransferMtx sync.Mutex
 	renameFrom      []string
 	restartPosition int
-
-	// message state
-	code   int
-	buffer []string
+	pretCommand     string
+	pretPath        string
 
 	// authentication
 	login string
 
+	// set by IDNT from a trusted bouncer
+	ident      string
+	hostname   string
+	remoteAddr net.Addr
+
 	// fs abstract away?
 	currentDir string
+
+	// timeouts, accessed atomically as they are checked by watchTimeouts.
+	// idleTimeout is in seconds
+	connectedAt  time.Time
+	lastActivity int64
+	idleTimeout  int64
+	loggedIn     int32
 }
 
 // Control gets the underlying connection
 func (s *Session) Control() net.Conn { return s.control }
 
+// RemoteAddr is the address of the client, when the connection came through
+// a trusted proxy this is the one from the PROXY header, and a trusted bouncer
+// can set it with IDNT
+func (s *Session) RemoteAddr() net.Addr {
+	if s.remoteAddr != nil {
+		return s.remoteAddr
+	}
+	return s.control.RemoteAddr()
+}
+
+// SetRemoteAddr sets the current state of the session
+func (s *Session) SetRemoteAddr(t net.Addr) { s.remoteAddr = t }
+
+// TrustedBouncer checks to see if the connection is from a bouncer allowed
+// to send IDNT
+func (s *Session) TrustedBouncer() bool { return containsIP(s.listener.idntFrom, s.control) }
+
+// SetIdent sets the current state of the session
+func (s *Session) SetIdent(t string) { s.ident = t }
+
+// Ident shows the ident sent by a bouncer, if any
+func (s *Session) Ident() string { return s.ident }
+
+// SetHostname sets the current state of the session
+func (s *Session) SetHostname(t string) { s.hostname = t }
+
+// Hostname shows the hostname sent by a bouncer, if any
+func (s *Session) Hostname() string { return s.hostname }
+
 // SetState sets the current state of the session
-func (s *Session) SetState(state cmd.SessionState) { s.state = state }
+func (s *Session) SetState(state cmd.SessionState) {
+	s.state = state
+
+	var loggedIn int32
+	if state == cmd.SessionStateLoggedIn {
+		loggedIn = 1
+	}
+	atomic.StoreInt32(&s.loggedIn, loggedIn)
+}
 
 // State shows the current state of the session
 func (s *Session) State() cmd.SessionState { return s.state }
@@ -66,18 +140,66 @@ func (s *Session) SetBinaryMode(t bool) { s.binaryMode = t }
 // BinaryMode shows the current state of the session
 func (s *Session) BinaryMode() bool { return s.binaryMode }
 
+// SetTransferMode sets the current state of the session
+func (s *Session) SetTransferMode(t cmd.TransferMode) { s.transferMode = t }
+
+// TransferMode shows the current state of the session
+func (s *Session) TransferMode() cmd.TransferMode { return s.transferMode }
+
+// SetDeflateLevel sets the compression level used in MODE Z
+func (s *Session) SetDeflateLevel(t int) { s.deflateLevel = t }
+
+// DeflateLevel shows the compression level used in MODE Z
+func (s *Session) DeflateLevel() int { return s.deflateLevel }
+
 // SetDataProtected sets the current state of the session
 func (s *Session) SetDataProtected(t bool) { s.dataProtected = t }
 
 // DataProtected shows the current state of the session
 func (s *Session) DataProtected() bool { return s.dataProtected }
 
+// SetSSCN sets the current state of the session
+func (s *Session) SetSSCN(t bool) { s.sscn = t }
+
+// SSCN shows the current state of the session
+func (s *Session) SSCN() bool { return s.sscn }
+
+// SetEPSVAll sets the current state of the session
+func (s *Session) SetEPSVAll(t bool) { s.epsvAll = t }
+
+// EPSVAll shows the current state of the session
+func (s *Session) EPSVAll() bool { return s.epsvAll }
+
+// SetMLSTFacts sets the facts returned by MLST and MLSD
+func (s *Session) SetMLSTFacts(t []string) { s.mlstFacts = t }
+
+// MLSTFacts shows the facts returned by MLST and MLSD
+func (s *Session) MLSTFacts() []string { return s.mlstFacts }
+
+// SetHashAlgorithm sets the algorithm used by HASH
+func (s *Session) SetHashAlgorithm(t string) { s.hashAlgorithm = t }
+
+// HashAlgorithm shows the algorithm used by HASH
+func (s *Session) HashAlgorithm() string { return s.hashAlgorithm }
+
+// SetRange sets the byte range set by RANG, end is exclusive and 0 means EOF
+func (s *Session) SetRange(start, end int64) { s.rangeStart, s.rangeEnd = start, end }
+
+// Range shows the byte range set by RANG
+func (s *Session) Range() (int64, int64) { return s.rangeStart, s.rangeEnd }
+
 // SetRestartPosition sets the current state of the session
 func (s *Session) SetRestartPosition(t int) { s.restartPosition = t }
 
 // RestartPosition shows the current state of the session
 func (s *Session) RestartPosition() int { return s.restartPosition }
 
+// SetPRET sets the command and path announced by PRET
+func (s *Session) SetPRET(command, path string) { s.pretCommand, s.pretPath = command, path }
+
+// PRET shows the command and path announced by PRET
+func (s *Session) PRET() (string, string) { return s.pretCommand, s.pretPath }
+
 // SetRenameFrom sets the current state of the session
 func (s *Session) SetRenameFrom(t []string) { s.renameFrom = t }
 
@@ -88,7 +210,43 @@ func (s *Session) CWD() string { return s.currentDir }
 func (s *Session) SetCWD(t string) { s.currentDir = t }
 
 // LastCommnad returns the last command to be successful
-func (s *Session) LastCommand() string { return s.lastCommand }
+func (s *Session) LastCommand() string {
+	s.lastCommandMtx.Lock()
+	defer s.lastCommandMtx.Unlock()
+	return s.lastCommand
+}
+
+// setLastCommand is guarded as transfers finish in the background
+func (s *Session) setLastCommand(t string) {
+	s.lastCommandMtx.Lock()
+	s.lastCommand = t
+	s.lastCommandMtx.Unlock()
+}
+
+// LastTransfer returns the last RETR, STOR or APPE to complete
+func (s *Session) LastTransfer() *cmd.TransferStats {
+	s.lastTransferMtx.Lock()
+	defer s.lastTransferMtx.Unlock()
+	return s.lastTransfer
+}
+
+// RecordTransfer writes the transfer to the transfer log. Completed ones
+// are kept as the last transfer and on the running transfer for post hooks
+func (s *Session) RecordTransfer(t cmd.TransferStats) {
+	s.server.logTransfer(s, &t)
+
+	if !t.Complete {
+		return
+	}
+
+	s.lastTransferMtx.Lock()
+	s.lastTransfer = &t
+	s.lastTransferMtx.Unlock()
+
+	if current := s.currentTransfer(); current != nil {
+		current.stats.Store(&t)
+	}
+}
 
 // RenameFrom shows the current state of the session
 func (s *Session) RenameFrom() []string { return s.renameFrom }
@@ -101,16 +259,16 @@ func (s *Session) Login() string { return s.login }
 
 func (s *Session) Data() cmd.DataConn { return s.data }
 func (s *Session) ClearData()         { s.data = nil }
-func (s *Session) NewPassiveDataConn(ctx context.Context) error {
-	d, err := s.server.newPassiveDataConn(ctx, s.dataProtected)
+func (s *Session) NewPassiveDataConn(ctx context.Context, tlsClient bool) error {
+	d, err := s.server.newPassiveDataConn(ctx, s.listener, s.dataProtected, tlsClient)
 	if err != nil {
 		return err
 	}
 	s.data = d
 	return nil
 }
-func (s *Session) NewActiveDataConn(ctx context.Context, params string) error {
-	d, err := s.server.newActiveDataConn(ctx, params, s.dataProtected)
+func (s *Session) NewActiveDataConn(ctx context.Context, addr string, tlsClient bool) error {
+	d, err := s.server.newActiveDataConn(ctx, addr, s.dataProtected, tlsClient)
 	if err != nil {
 		return err
 	}
@@ -136,27 +294,51 @@ func (s *Session) User() *acl.User {
 // Reset is used by sync.Pool and helps to minimise allocations
 func (s *Session) Reset() {
 	s.server = nil
+	s.listener = nil
 
 	s.activeMtx.Lock()
 	s.active = false
 	s.activeMtx.Unlock()
 
+	s.controlMtx.Lock()
 	s.control = nil
+	s.id = 0
+	s.controlMtx.Unlock()
+
 	s.data = nil
+	s.transfer = nil
 
 	s.state = cmd.SessionStateNull
 	s.dataProtected = false
+	s.sscn = false
 	s.binaryMode = false
+	s.transferMode = cmd.TransferModeStream
+	s.deflateLevel = cmd.DefaultDeflateLevel
+	s.epsvAll = false
+	s.mlstFacts = cmd.DefaultMLSTFacts
+	s.hashAlgorithm = vfs.HashSHA256
+	s.rangeStart = 0
+	s.rangeEnd = 0
 	s.lastCommand = ""
+	s.lastTransfer = nil
 	s.renameFrom = []string{}
 	s.restartPosition = 0
+	s.pretCommand = ""
+	s.pretPath = ""
 
-	s.code = 0
-	s.buffer = []string{}
+	s.replyBuffer = replyBuffer{session: s}
 
 	s.login = ""
+	s.ident = ""
+	s.hostname = ""
+	s.remoteAddr = nil
 
 	s.currentDir = "/"
+
+	s.connectedAt = time.Time{}
+	atomic.StoreInt64(&s.lastActivity, 0)
+	atomic.StoreInt64(&s.idleTimeout, 0)
+	atomic.StoreInt32(&s.loggedIn, 0)
 }
 
 // Close attempts to gracefully close the control and any running
@@ -166,8 +348,13 @@ func (s *Session) Close() error {
 	s.active = false
 	s.activeMtx.Unlock()
 
-	if err := s.control.Close(); err != nil {
-		return err
+	// a transfer may have closed the control connection already, so carry
+	// on regardless
+	err := s.control.Close()
+
+	// wait for any transfer so nothing is using the session once we return
+	if t := s.currentTransfer(); t != nil {
+		t.Abort()
 	}
 
 	if s.data != nil {
@@ -176,58 +363,121 @@ func (s *Session) Close() error {
 		}
 	}
 
-	return nil
+	return err
+}
+
+const shutdownMessage = "Server is shutting down, goodbye."
+
+// disconnectWriteTimeout stops a client that isn't reading from holding up
+// disconnect
+const disconnectWriteTimeout = time.Second * 5
+
+// disconnect replies with a 421 and closes the control connection, which
+// stops the reader in serve and ends the session. It writes the reply itself
+// so is safe to call from outside the control goroutine
+func (s *Session) disconnect(reason string) {
+	// AUTH TLS swaps the control connection under the lock
+	s.controlMtx.Lock()
+	defer s.controlMtx.Unlock()
+
+	s.disconnectLocked(reason)
+}
+
+// disconnectIf disconnects the session only if it still has the registry
+// id, it may have ended and been reused by another connection since the id
+// was looked up
+func (s *Session) disconnectIf(id uint64, reason string) {
+	s.controlMtx.Lock()
+	defer s.controlMtx.Unlock()
+
+	if s.id != id || s.control == nil {
+		return
+	}
+
+	s.disconnectLocked(reason)
+}
+
+func (s *Session) disconnectLocked(reason string) {
+	s.control.SetWriteDeadline(time.Now().Add(disconnectWriteTimeout))
+
+	_, err := fmt.Fprintf(s.control.writer, "%d %s\r\n", cmd.StatusServiceUnavailable.Code, reason)
+	if err == nil {
+		err = s.control.writer.Flush()
+	}
+
+	s.control.Close()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR flush disconnect %s: %s\n", s.RemoteAddr(), err)
+	}
+}
+
+// replyBuffer collects replies until they are flushed to the control
+// connection
+type replyBuffer struct {
+	session *Session
+
+	sbuilder strings.Builder
+
+	// message state
+	code   int
+	buffer []string
 }
 
 // Reply replies with the int and message, mainly for scripts convenience
-func (s *Session) Reply(code int, message string) {
-	s.reply(code, message)
+func (r *replyBuffer) Reply(code int, message string) {
+	r.reply(code, message)
 }
 
 // ReplyStatus replies with the default message for a status code
-func (s *Session) ReplyStatus(st cmd.Status) {
-	s.reply(st.Code, st.Message)
+func (r *replyBuffer) ReplyStatus(st cmd.Status) {
+	r.reply(st.Code, st.Message)
 }
 
 // ReplyStatusArgs replies with the default message for a status code
 // but takes args
-func (s *Session) ReplyWithArgs(st cmd.Status, args ...interface{}) {
-	s.reply(st.Code, fmt.Sprintf(st.Message, args...))
+func (r *replyBuffer) ReplyWithArgs(st cmd.Status, args ...interface{}) {
+	r.reply(st.Code, fmt.Sprintf(st.Message, args...))
 }
 
 // ReplyError replies with the default message for a status code
 // but takes args
-func (s *Session) ReplyError(st cmd.Status, err error) {
-	s.reply(st.Code, fmt.Sprintf("%s (%s)", st.Message, err.Error()))
+func (r *replyBuffer) ReplyError(st cmd.Status, err error) {
+	r.reply(st.Code, fmt.Sprintf("%s (%s)", st.Message, err.Error()))
 }
 
 // ReplyWithMessage replies with custom message
-func (s *Session) ReplyWithMessage(st cmd.Status, message string) {
-	s.reply(st.Code, message)
+func (r *replyBuffer) ReplyWithMessage(st cmd.Status, message string) {
+	r.reply(st.Code, message)
 }
 
 // reply is the underlying code for splitting a message across multiple lines
-func (s *Session) reply(code int, message string) {
+func (r *replyBuffer) reply(code int, message string) {
 	parts := strings.Split(message, "\n")
 
-	s.code = code
+	r.code = code
 	for idx := range parts {
 		if len(parts[idx]) > 0 {
-			s.buffer = append(s.buffer, parts[idx])
+			r.buffer = append(r.buffer, parts[idx])
 		}
 	}
 }
 
-func (s *Session) Flush() error {
-	defer func() {
-		s.code = 0
-		s.buffer = nil
-	}()
+// discard throws away anything not yet flushed
+func (r *replyBuffer) discard() {
+	r.code = 0
+	r.buffer = nil
+}
+
+func (r *replyBuffer) Flush() error {
+	defer r.discard()
 
-	if len(s.buffer) == 0 {
+	if len(r.buffer) == 0 {
 		return nil
 	}
 
+	s := r.session
+
 	s.activeMtx.Lock()
 	if !s.active {
 		s.activeMtx.Unlock()
@@ -235,38 +485,42 @@ func (s *Session) Flush() error {
 	}
 	s.activeMtx.Unlock()
 
-	s.sbuilder.Reset()
+	r.sbuilder.Reset()
 
-	if _, err := s.sbuilder.WriteString(fmt.Sprintf("%d", s.code)); err != nil {
+	if _, err := r.sbuilder.WriteString(fmt.Sprintf("%d", r.code)); err != nil {
 		return cmd.NewFatalError(err)
 	}
 
-	if len(s.buffer) > 1 {
-		if _, err := s.sbuilder.WriteString("-"); err != nil {
+	if len(r.buffer) > 1 {
+		if _, err := r.sbuilder.WriteString("-"); err != nil {
 			return cmd.NewFatalError(err)
 		}
 	}
 
-	if _, err := s.sbuilder.WriteString(" "); err != nil {
+	if _, err := r.sbuilder.WriteString(" "); err != nil {
 		return cmd.NewFatalError(err)
 	}
 
-	for _, p := range s.buffer {
+	for _, p := range r.buffer {
 		if len(p) == 0 {
 			continue
 		}
-		if _, err := s.sbuilder.WriteString(p + "\r\n"); err != nil {
+		if _, err := r.sbuilder.WriteString(p + "\r\n"); err != nil {
 			return cmd.NewFatalError(err)
 		}
 	}
 
-	if len(s.buffer) > 1 {
-		if _, err := s.sbuilder.WriteString(fmt.Sprintf("%d End.\r\n", s.code)); err != nil {
+	if len(r.buffer) > 1 {
+		if _, err := r.sbuilder.WriteString(fmt.Sprintf("%d End.\r\n", r.code)); err != nil {
 			return cmd.NewFatalError(err)
 		}
 	}
 
-	_, err := s.control.writer.WriteString(s.sbuilder.String())
+	// a transfer and the control goroutine can both be replying
+	s.controlMtx.Lock()
+	defer s.controlMtx.Unlock()
+
+	_, err := s.control.writer.WriteString(r.sbuilder.String())
 	if err != nil {
 		return cmd.NewFatalError(err)
 	}
@@ -276,7 +530,7 @@ func (s *Session) Flush() error {
 	}
 
 	if len(os.Getenv("DEBUG")) > 0 {
-		fmt.Fprintf(os.Stderr, ">>> %s", s.sbuilder.String())
+		fmt.Fprintf(os.Stderr, ">>> %s", r.sbuilder.String())
 	}
 
 	return nil
@@ -289,14 +543,16 @@ func (s *Session) Upgrade() error {
 		return err
 	}
 
+	s.controlMtx.Lock()
 	s.control = newControl(tlsConn)
+	s.controlMtx.Unlock()
 
 	return nil
 }
 
 // serve takes a connection and fs and parses commands on the control channel
 // it traps any panics and attempts to close the session
-func (s *Session) serve(ctx context.Context, server *Server, conn net.Conn) {
+func (s *Session) serve(ctx context.Context, server *Server, l *listener, conn net.Conn) {
 	defer func() {
 		if e := recover(); e != nil {
 			var buf bytes.Buffer
@@ -319,22 +575,53 @@ func (s *Session) serve(ctx context.Context, server *Server, conn net.Conn) {
 
 	s.control = newControl(conn)
 	s.server = server
+	s.listener = l
 	s.active = true
 
+	s.connectedAt = time.Now()
+	s.touch()
+	s.SetIdleTimeout(server.options().IdleTimeout)
+
+	// implicit TLS is as if AUTH TLS and PROT P have already been sent
+	if l.ImplicitTLS {
+		if err := s.Upgrade(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR implicit tls handshake %s: %s\n", s.RemoteAddr(), err)
+			return
+		}
+
+		s.state = cmd.SessionStateAuth
+		s.dataProtected = true
+	}
+
+	if err := server.addSession(s); err != nil {
+		s.ReplyError(cmd.StatusServiceUnavailable, err)
+		if err := s.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR flush session limit %s: %s\n", s.RemoteAddr(), err)
+		}
+		return
+	}
+	defer server.removeSession(s)
+
 	s.ReplyWithMessage(cmd.StatusServiceReady, "Welcome!")
 	if err := s.Flush(); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR flush session welcome: %s\n", err)
+		fmt.Fprintf(os.Stderr, "ERROR flush session welcome %s: %s\n", s.RemoteAddr(), err)
 		return
 	}
 
 	defer s.Close()
 
+	done := make(chan struct{})
+	defer close(done)
+	go s.watchTimeouts(done)
+
 	for {
 		line, err := s.control.reader.ReadString('\n')
 		if err != nil {
 			break
 		}
 
+		s.touch()
+
 		if len(os.Getenv("DEBUG")) > 0 {
 			fmt.Fprintf(os.Stderr, "<<< %s", line)
 		}
@@ -352,40 +639,77 @@ func (s *Session) serve(ctx context.Context, server *Server, conn net.Conn) {
 			continue
 		}
 
-		if err := s.handleCommand(ctx, fields); err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR handleCommand: %s\n", err)
+		ftpCommand := strings.ToUpper(fields[0])
+
+		// while a transfer is running only ABOR and STAT are handled, anything
+		// else waits for it to finish
+		if t := s.currentTransfer(); t != nil && ftpCommand != "ABOR" && ftpCommand != "STAT" {
+			<-t.done
+		}
+
+		// don't start anything new once shutting down
+		if server.shuttingDown() && s.currentTransfer() == nil {
+			s.disconnect(shutdownMessage)
+			return
+		}
+
+		// only the command words, the arguments could be a password
+		command := ftpCommand
+		if ftpCommand == "SITE" && len(fields) > 1 {
+			command += " " + strings.ToUpper(fields[1])
+		}
+		s.updateEntry(command)
+
+		if cmd.TransferCommands[ftpCommand] && s.data != nil {
+			s.startTransfer(ctx, ftpCommand, fields)
+			continue
+		}
+
+		err = s.handleCommand(ctx, s, fields)
+
+		s.updateEntry("")
+
+		if err := s.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR session flush %s: %s\n", s.RemoteAddr(), err)
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR handleCommand %s: %s\n", s.RemoteAddr(), err)
 			break
 		}
 	}
 }
 
 // handleCommand takes in the client input in the form of a slice of strings
-// and tries to find and execute a command. can return an error
-func (session *Session) handleCommand(ctx context.Context, fields []string) error {
-	// start := time.Now()
-
+// and tries to find and execute a command. can return an error. Replies are
+// made through cs, which is either the session or a transfer running in the
+// background, and are left for the caller to flush
+func (session *Session) handleCommand(ctx context.Context, cs cmd.Session, fields []string) error {
 	ftpCommand := strings.ToUpper(fields[0])
 
-	defer func() {
-		// log.Printf("%s - %s", ftpCommand, time.Since(start))
-		if err := session.Flush(); err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR session flush: %s\n", err)
-		}
-	}()
-
 	// TODO: ugly as sin
 	c, ok := cmd.CommandMap[ftpCommand]
+	params := fields[1:]
+
+	// native SITE commands, any others are left to scripts
+	var site string
+	if !ok && ftpCommand == "SITE" && len(fields) > 1 {
+		site = strings.ToUpper(fields[1])
+		if c, ok = cmd.SiteCommandMap[site]; ok {
+			params = fields[2:]
+		}
+	}
 
 	if !ok {
 
 		// if logged in, check if we have a script that uses this command
 		if session.State() == cmd.SessionStateLoggedIn {
-			err := session.server.se.Do(ctx, fields, script.ScriptHookCommand, session)
+			err := session.server.scripts().Do(ctx, fields, script.ScriptHookCommand, cs)
 
 			switch err {
 
 			case script.ErrNotExist:
-				session.ReplyStatus(cmd.StatusNotImplemented)
+				cs.ReplyStatus(cmd.StatusNotImplemented)
 				break
 
 			case script.ErrStop:
@@ -395,26 +719,32 @@ func (session *Session) handleCommand(ctx context.Context, fields []string) erro
 				break
 
 			default:
-				session.Reply(500, "Error in script.")
+				cs.Reply(500, "Error in script.")
 				return err
 			}
 
 			return nil
 		}
 
-		session.ReplyStatus(cmd.StatusNotImplemented)
+		cs.ReplyStatus(cmd.StatusNotImplemented)
 
 		return nil
 	}
 
-	if session.State() < c.RequireState() {
+	// a plaintext listener doesn't need AUTH TLS before logging in
+	state := session.State()
+	if state == cmd.SessionStateNull && session.listener.AllowPlaintext {
+		state = cmd.SessionStateAuth
+	}
+
+	if state < c.RequireState() {
 		switch c.RequireState() {
 		case cmd.SessionStateAuth:
-			session.ReplyWithMessage(cmd.StatusBadCommandSequence, "Please send AUTH first.")
+			cs.ReplyWithMessage(cmd.StatusBadCommandSequence, "Please send AUTH first.")
 		case cmd.SessionStateLoggedIn:
-			session.ReplyStatus(cmd.StatusNotLoggedIn)
+			cs.ReplyStatus(cmd.StatusNotLoggedIn)
 		default:
-			session.ReplyStatus(cmd.StatusNotImplemented)
+			cs.ReplyStatus(cmd.StatusNotImplemented)
 		}
 		return nil
 	}
@@ -424,20 +754,25 @@ func (session *Session) handleCommand(ctx context.Context, fields []string) erro
 		if user == nil || !user.DeletedAt.IsZero() {
 			return errors.New("deleted user")
 		}
+
+		if len(site) > 0 && !session.server.options().siteAllowed(site, user) {
+			cs.ReplyStatus(cmd.StatusPermissionDenied)
+			return nil
+		}
 	}
 
 	// pre command hook
-	if err := session.server.se.Do(ctx, fields, script.ScriptHookPre, session); err != nil {
+	if err := session.server.scripts().Do(ctx, fields, script.ScriptHookPre, cs); err != nil {
 		if err != script.ErrNotExist {
 			if err == script.ErrStop {
 				return nil
 			}
-			session.Reply(500, "Error in script.")
+			cs.Reply(500, "Error in script.")
 			return err
 		}
 	}
 
-	if err := c.Execute(ctx, session, fields[1:]); err != nil {
+	if err := c.Execute(ctx, cs, params); err != nil {
 		// check the type of the error, if its a fatal err then
 		// return it, otherwise return nil to continue
 		if errors.Is(err, cmd.ErrCommandFatal) {
@@ -447,15 +782,15 @@ func (session *Session) handleCommand(ctx context.Context, fields []string) erro
 		return nil
 	}
 
-	session.lastCommand = ftpCommand
+	session.setLastCommand(ftpCommand)
 
 	// post command hook
-	if err := session.server.se.Do(ctx, fields, script.ScriptHookPost, session); err != nil {
+	if err := session.server.scripts().Do(ctx, fields, script.ScriptHookPost, cs); err != nil {
 		if err != script.ErrNotExist {
 			if err == script.ErrStop {
 				return nil
 			}
-			session.Reply(500, "Error in script.")
+			cs.Reply(500, "Error in script.")
 			return err
 		}
 	}
@@ -0,0 +1,43 @@
+package ftp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+func TestSiteIDLE(t *testing.T) {
+	ts := newTestServer(t, func(opts *ServerOpts) {
+		a, err := acl.NewFromString("*")
+		if err != nil {
+			t.Fatalf("unexpected error creating acl: %s", err)
+		}
+		opts.SetSiteACL("IDLE", a)
+	})
+
+	c := ts.login(t, "alice")
+
+	if msg := c.cmd(200, "SITE IDLE"); !strings.Contains(msg, "60 seconds (max 60)") {
+		t.Errorf("expected the server's idle timeout in %q", msg)
+	}
+
+	c.cmd(200, "SITE IDLE 30")
+
+	if msg := c.cmd(200, "SITE IDLE"); !strings.Contains(msg, "30 seconds (max 60)") {
+		t.Errorf("expected the lowered idle timeout in %q", msg)
+	}
+
+	// can't be raised past the server's
+	c.cmd(550, "SITE IDLE 61")
+	c.cmd(501, "SITE IDLE 0")
+	c.cmd(501, "SITE IDLE abc")
+	c.cmd(501, "SITE IDLE 1 2")
+}
+
+func TestSiteIDLEPermissionDenied(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	c := ts.login(t, "alice")
+	c.cmd(550, "SITE IDLE 30")
+}
@@ -0,0 +1,25 @@
+package ftp
+
+import (
+	"testing"
+)
+
+func TestSIZE(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/a.txt", []byte("one\ntwo\n"))
+
+	c := ts.login(t, "alice")
+
+	// sent with CRLF line endings
+	c.cmd(200, "TYPE A")
+	if msg := c.cmd(213, "SIZE a.txt"); msg != "213 10" {
+		t.Errorf("expected 213 10 got %q", msg)
+	}
+
+	c.cmd(200, "TYPE I")
+	if msg := c.cmd(213, "SIZE a.txt"); msg != "213 8" {
+		t.Errorf("expected 213 8 got %q", msg)
+	}
+
+	c.cmd(550, "SIZE missing.txt")
+}
@@ -0,0 +1,352 @@
+package ftp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// PathSpeedOpts overrides the speed limits for transfers of files matching
+// Path. Download and Upload are KB/s for each transfer on its own, 0 is
+// unlimited, and the global, group and user limits don't apply
+type PathSpeedOpts struct {
+	Path     string
+	Download int
+	Upload   int
+}
+
+type pathSpeed struct {
+	*PathSpeedOpts
+	g glob.Glob
+}
+
+func newPathSpeed(opts *PathSpeedOpts) (*pathSpeed, error) {
+	g, err := glob.Compile(opts.Path, '/')
+	if err != nil {
+		return nil, fmt.Errorf("path_speed '%s': %w", opts.Path, err)
+	}
+
+	return &pathSpeed{
+		PathSpeedOpts: opts,
+		g:             g,
+	}, nil
+}
+
+// minBurst stops very low limits from sending tiny packets
+const minBurst = 4096
+
+// bucket is a token bucket, rate is bytes a second and 0 is unlimited. The
+// bucket holds at most a second's worth, so after being idle a transfer
+// can't go over the limit for more than a second
+type bucket struct {
+	mtx    sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	// shared buckets are kept in Server.buckets under key while refs, the
+	// number of transfers using them, is above 0. Guarded by bucketsMtx
+	key  string
+	refs int
+}
+
+func newBucket(kbps int) *bucket {
+	var b bucket
+	b.setRate(kbps)
+	return &b
+}
+
+// setRate changes the limit, given in KB/s
+func (b *bucket) setRate(kbps int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	rate := float64(kbps) * 1024
+	if rate == b.rate {
+		return
+	}
+
+	b.rate = rate
+	b.tokens = 0
+	b.last = time.Now()
+}
+
+func (b *bucket) burst() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.rate <= 0 {
+		return 0
+	}
+
+	if b.rate < minBurst {
+		return minBurst
+	}
+
+	return int(b.rate)
+}
+
+// take removes n tokens and returns how long to wait before they would have
+// been available. The bucket goes into debt so everyone sharing it waits
+// their turn
+func (b *bucket) take(n int) time.Duration {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// throttledDataConn wraps a cmd.DataConn limiting how fast it can be read
+// from or written to. Each read or write takes from every bucket, waiting
+// for the slowest
+type throttledDataConn struct {
+	cmd.DataConn
+
+	buckets []*bucket
+
+	// the largest read or write, so a single one doesn't wait for long
+	chunk int
+}
+
+func newThrottledDataConn(d cmd.DataConn, buckets []*bucket) *throttledDataConn {
+	t := throttledDataConn{
+		DataConn: d,
+		buckets:  buckets,
+	}
+
+	for _, b := range buckets {
+		if burst := b.burst(); burst > 0 && (t.chunk == 0 || burst < t.chunk) {
+			t.chunk = burst
+		}
+	}
+
+	return &t
+}
+
+func (d *throttledDataConn) wait(n int) {
+	var wait time.Duration
+
+	for _, b := range d.buckets {
+		if w := b.take(n); w > wait {
+			wait = w
+		}
+	}
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Read implements the io.Reader interface
+func (d *throttledDataConn) Read(p []byte) (int, error) {
+	if d.chunk > 0 && len(p) > d.chunk {
+		p = p[:d.chunk]
+	}
+
+	n, err := d.DataConn.Read(p)
+	if n > 0 {
+		d.wait(n)
+	}
+
+	return n, err
+}
+
+// Write implements the io.Writer interface
+func (d *throttledDataConn) Write(p []byte) (int, error) {
+	var written int
+
+	for len(p) > 0 {
+		chunk := p
+		if d.chunk > 0 && len(chunk) > d.chunk {
+			chunk = chunk[:d.chunk]
+		}
+
+		d.wait(len(chunk))
+
+		n, err := d.DataConn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// ZeroCopy implements cmd.ZeroCopier when the connection it wraps does,
+// sending a chunk at a time and waiting after each
+func (d *throttledDataConn) ZeroCopy(r io.Reader) (int64, bool, error) {
+	zc, ok := d.DataConn.(cmd.ZeroCopier)
+	if !ok {
+		return 0, false, nil
+	}
+
+	if d.chunk == 0 {
+		return zc.ZeroCopy(r)
+	}
+
+	var total int64
+
+	for {
+		n, sent, err := zc.ZeroCopy(&io.LimitedReader{R: r, N: int64(d.chunk)})
+		if !sent {
+			return total, total > 0, err
+		}
+
+		total += n
+
+		if n > 0 {
+			d.wait(int(n))
+		}
+
+		if err != nil || n < int64(d.chunk) {
+			return total, true, err
+		}
+	}
+}
+
+// sharedBucket returns the bucket shared by every transfer with the same
+// key, updating its limit as users and groups can be changed at any time.
+// Each call has to be matched by releaseBuckets once the transfer ends
+func (server *Server) sharedBucket(key string, kbps int) *bucket {
+	server.bucketsMtx.Lock()
+	defer server.bucketsMtx.Unlock()
+
+	b, ok := server.buckets[key]
+	if !ok {
+		b = newBucket(kbps)
+		b.key = key
+		server.buckets[key] = b
+	} else {
+		b.setRate(kbps)
+	}
+
+	b.refs++
+
+	return b
+}
+
+// releaseBuckets is called when a transfer ends, dropping any shared bucket
+// it was the last to use
+func (server *Server) releaseBuckets(buckets []*bucket) {
+	server.bucketsMtx.Lock()
+	defer server.bucketsMtx.Unlock()
+
+	for _, b := range buckets {
+		if len(b.key) == 0 {
+			continue
+		}
+
+		b.refs--
+		if b.refs <= 0 {
+			delete(server.buckets, b.key)
+		}
+	}
+}
+
+// speedLimits returns the buckets a transfer of path has to take from. A
+// path_speed matching the path replaces the rest with a bucket of its own.
+// Otherwise there are the global limit, the primary group's, shared by all
+// of its users, and the user's, shared by their sessions. A user without
+// their own limit gets the server default
+func (s *Session) speedLimits(ftpCommand, path string) []*bucket {
+	server := s.server
+
+	upload := ftpCommand != "RETR"
+
+	server.optsMtx.RLock()
+	opts := server.opts
+	pathSpeeds := server.pathSpeeds
+	global := server.downloadBucket
+	if upload {
+		global = server.uploadBucket
+	}
+	server.optsMtx.RUnlock()
+
+	for _, ps := range pathSpeeds {
+		if !ps.g.Match(path) {
+			continue
+		}
+
+		kbps := ps.Download
+		if upload {
+			kbps = ps.Upload
+		}
+
+		if kbps <= 0 {
+			return nil
+		}
+
+		return []*bucket{newBucket(kbps)}
+	}
+
+	direction := "download"
+	if upload {
+		direction = "upload"
+	}
+
+	var buckets []*bucket
+
+	if global != nil {
+		buckets = append(buckets, global)
+	}
+
+	user := s.User()
+	if user == nil {
+		return buckets
+	}
+
+	userSpeed, defaultSpeed := user.DownloadSpeed, opts.UserDownloadSpeed
+	if upload {
+		userSpeed, defaultSpeed = user.UploadSpeed, opts.UserUploadSpeed
+	}
+
+	if userSpeed == 0 {
+		userSpeed = defaultSpeed
+	}
+
+	if userSpeed > 0 {
+		key := fmt.Sprintf("user:%s:%s", direction, strings.ToLower(user.Name))
+		buckets = append(buckets, server.sharedBucket(key, userSpeed))
+	}
+
+	if len(user.PrimaryGroup) > 0 {
+		if g, err := server.auth.GetGroup(user.PrimaryGroup); err == nil {
+			groupSpeed := g.DownloadSpeed
+			if upload {
+				groupSpeed = g.UploadSpeed
+			}
+
+			if groupSpeed > 0 {
+				key := fmt.Sprintf("group:%s:%s", direction, strings.ToLower(g.Name))
+				buckets = append(buckets, server.sharedBucket(key, groupSpeed))
+			}
+		}
+	}
+
+	return buckets
+}
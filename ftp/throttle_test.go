@@ -0,0 +1,257 @@
+package ftp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// writesDataConn records the size of each write
+type writesDataConn struct {
+	bufferDataConn
+	writes []int
+}
+
+func (d *writesDataConn) Write(p []byte) (int, error) {
+	d.writes = append(d.writes, len(p))
+	return d.bufferDataConn.Write(p)
+}
+
+func TestBucket(t *testing.T) {
+	if w := newBucket(0).take(1 << 20); w != 0 {
+		t.Fatalf("expected no wait when unlimited, got %s", w)
+	}
+
+	if burst := newBucket(1).burst(); burst != minBurst {
+		t.Fatalf("expected a burst of %d got %d", minBurst, burst)
+	}
+
+	if burst := newBucket(100).burst(); burst != 100*1024 {
+		t.Fatalf("expected a burst of %d got %d", 100*1024, burst)
+	}
+
+	b := newBucket(1)
+
+	// starts empty, so a second's worth waits a second
+	if w := b.take(1024); w <= 900*time.Millisecond || w > time.Second {
+		t.Fatalf("expected to wait about a second, got %s", w)
+	}
+
+	// and in debt, so the next waits for both
+	if w := b.take(1024); w <= 1900*time.Millisecond || w > 2*time.Second {
+		t.Fatalf("expected to wait about two seconds, got %s", w)
+	}
+
+	// the same limit keeps the debt
+	b.setRate(1)
+	if w := b.take(0); w <= time.Second {
+		t.Fatalf("expected the debt to be kept, got %s", w)
+	}
+
+	// a new one starts over
+	b.setRate(2)
+	if w := b.take(0); w != 0 {
+		t.Fatalf("expected no wait after changing the limit, got %s", w)
+	}
+
+	// idle for an hour only saves up a second's worth
+	b.last = time.Now().Add(-time.Hour)
+	if w := b.take(2048); w != 0 {
+		t.Fatalf("expected no wait after being idle, got %s", w)
+	}
+	if w := b.take(2048); w <= 900*time.Millisecond || w > time.Second {
+		t.Fatalf("expected to wait about a second, got %s", w)
+	}
+}
+
+func TestThrottledDataConnChunks(t *testing.T) {
+	slow, fast := newBucket(16), newBucket(32)
+	for _, b := range []*bucket{slow, fast} {
+		b.last = time.Now().Add(-time.Hour)
+	}
+
+	var conn writesDataConn
+
+	d := newThrottledDataConn(&conn, []*bucket{newBucket(0), fast, slow})
+
+	// the slowest bucket sets the largest write
+	if d.chunk != 16*1024 {
+		t.Fatalf("expected chunks of %d got %d", 16*1024, d.chunk)
+	}
+
+	payload := bytes.Repeat([]byte("x"), 16*1024+100)
+
+	n, err := d.Write(payload)
+	if err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+
+	if n != len(payload) || conn.Len() != len(payload) {
+		t.Fatalf("expected %d bytes written got %d (%d)", len(payload), n, conn.Len())
+	}
+
+	if len(conn.writes) != 2 || conn.writes[0] != 16*1024 || conn.writes[1] != 100 {
+		t.Fatalf("expected writes of [%d 100] got %v", 16*1024, conn.writes)
+	}
+
+	// reads are cut down to a chunk too
+	for _, b := range []*bucket{slow, fast} {
+		b.last = time.Now().Add(-time.Hour)
+	}
+
+	n, err = d.Read(make([]byte, 64*1024))
+	if err != nil {
+		t.Fatalf("unexpected error reading: %s", err)
+	}
+
+	if n != 16*1024 {
+		t.Fatalf("expected to read %d got %d", 16*1024, n)
+	}
+}
+
+func TestSpeedLimitsPath(t *testing.T) {
+	ts := newTestServer(t, func(opts *ServerOpts) {
+		opts.DownloadSpeed = 100
+		opts.UserDownloadSpeed = 50
+		opts.PathSpeeds = []*PathSpeedOpts{
+			{Path: "/fast/**", Download: 0, Upload: 0},
+			{Path: "/slow/**", Download: 10, Upload: 20},
+		}
+	})
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "NOOP")
+
+	s, _ := ts.entry(t, "alice")
+
+	tests := []struct {
+		command string
+		path    string
+		rates   []float64
+	}{
+		{"RETR", "/fast/a.bin", nil},
+		{"STOR", "/fast/a.bin", nil},
+		{"RETR", "/slow/a.bin", []float64{10 * 1024}},
+		{"STOR", "/slow/a.bin", []float64{20 * 1024}},
+		{"APPE", "/slow/a.bin", []float64{20 * 1024}},
+		{"RETR", "/a.bin", []float64{100 * 1024, 50 * 1024}},
+		{"STOR", "/a.bin", nil},
+	}
+
+	for _, tt := range tests {
+		buckets := s.speedLimits(tt.command, tt.path)
+
+		if len(buckets) != len(tt.rates) {
+			t.Fatalf("%s %s: expected %d buckets got %d", tt.command, tt.path, len(tt.rates), len(buckets))
+		}
+
+		for i, b := range buckets {
+			if b.rate != tt.rates[i] {
+				t.Errorf("%s %s: expected bucket %d at %.0f got %.0f", tt.command, tt.path, i, tt.rates[i], b.rate)
+			}
+		}
+
+		ts.releaseBuckets(buckets)
+	}
+
+	// path limits are for each transfer on its own
+	a, b := s.speedLimits("RETR", "/slow/a.bin"), s.speedLimits("RETR", "/slow/a.bin")
+	if a[0] == b[0] {
+		t.Fatal("expected a bucket for each transfer of a path_speed")
+	}
+	ts.releaseBuckets(a)
+	ts.releaseBuckets(b)
+}
+
+func TestSpeedLimitsShared(t *testing.T) {
+	ts := newTestServer(t, func(opts *ServerOpts) {
+		opts.UserDownloadSpeed = 50
+	})
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "NOOP")
+
+	s, _ := ts.entry(t, "alice")
+
+	a, b := s.speedLimits("RETR", "/a.bin"), s.speedLimits("RETR", "/b.bin")
+	if len(a) != 1 || len(b) != 1 {
+		t.Fatalf("expected a bucket each got %d and %d", len(a), len(b))
+	}
+
+	if a[0] != b[0] {
+		t.Fatal("expected transfers of the same user to share a bucket")
+	}
+
+	// uploads are limited on their own, and there is no upload limit here
+	if up := s.speedLimits("STOR", "/a.bin"); len(up) != 0 {
+		t.Fatalf("expected no upload limit got %d buckets", len(up))
+	}
+
+	countBuckets := func() int {
+		ts.bucketsMtx.Lock()
+		defer ts.bucketsMtx.Unlock()
+		return len(ts.buckets)
+	}
+
+	ts.releaseBuckets(a)
+	if n := countBuckets(); n != 1 {
+		t.Fatalf("expected the bucket to be kept while in use, got %d", n)
+	}
+
+	ts.releaseBuckets(b)
+	if n := countBuckets(); n != 0 {
+		t.Fatalf("expected the bucket to be dropped, got %d", n)
+	}
+}
+
+func TestSpeedLimitsReleased(t *testing.T) {
+	ts := newTestServer(t, func(opts *ServerOpts) {
+		// slow enough that the transfer is still running when ABOR arrives
+		opts.UserDownloadSpeed = 16
+	})
+	ts.createFile(t, "/big.bin", bytes.Repeat([]byte("x"), 1024*1024))
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "TYPE I")
+
+	data := c.pasv()
+	c.cmd(150, "RETR big.bin")
+
+	buf := make([]byte, 1024)
+	if _, err := data.Read(buf); err != nil {
+		t.Fatalf("unexpected error reading data: %s", err)
+	}
+
+	refs := func() (int, int) {
+		ts.bucketsMtx.Lock()
+		defer ts.bucketsMtx.Unlock()
+
+		if b, ok := ts.buckets["user:download:alice"]; ok {
+			return len(ts.buckets), b.refs
+		}
+		return len(ts.buckets), 0
+	}
+
+	if n, r := refs(); n != 1 || r != 1 {
+		t.Fatalf("expected 1 bucket used once got %d used %d times", n, r)
+	}
+
+	c.send("ABOR")
+	c.expect(426)
+	c.expect(226)
+
+	// the bucket goes once the transfer has finished
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n, _ := refs()
+		if n == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the bucket to be dropped, got %d buckets", n)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,78 @@
+package ftp
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// IdleTimeout is how many seconds the session can go without sending a
+// command, 0 is forever
+func (s *Session) IdleTimeout() int { return int(atomic.LoadInt64(&s.idleTimeout)) }
+
+// SetIdleTimeout sets the current state of the session
+func (s *Session) SetIdleTimeout(t int) { atomic.StoreInt64(&s.idleTimeout, int64(t)) }
+
+// MaxIdleTimeout is the user's own idle timeout, or the server's when they
+// don't have one. SITE IDLE can only lower the session's below this
+func (s *Session) MaxIdleTimeout() int {
+	if user := s.User(); user != nil && user.IdleTimeout > 0 {
+		return user.IdleTimeout
+	}
+	return s.server.options().IdleTimeout
+}
+
+// touch records activity on the control connection
+func (s *Session) touch() { atomic.StoreInt64(&s.lastActivity, time.Now().UnixNano()) }
+
+// idle is how long it has been since the last activity
+func (s *Session) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&s.lastActivity)))
+}
+
+// checkTimeouts returns why the session should be disconnected, if it
+// should be. A running transfer counts as activity, one that has stalled is
+// ended by stall_timeout instead
+func (s *Session) checkTimeouts() string {
+	if s.currentTransfer() != nil {
+		s.touch()
+		return ""
+	}
+
+	login := time.Duration(s.server.options().LoginTimeout) * time.Second
+	if atomic.LoadInt32(&s.loggedIn) == 0 && login > 0 && time.Since(s.connectedAt) > login {
+		return "Login timeout, goodbye."
+	}
+
+	idle := s.IdleTimeout()
+	if idle > 0 && s.idle() > time.Duration(idle)*time.Second {
+		return fmt.Sprintf("Idle timeout (%d seconds), goodbye.", idle)
+	}
+
+	return ""
+}
+
+// watchTimeouts disconnects the session once it has been idle for too long
+// or hasn't logged in quick enough. Closing the control connection stops the
+// reader in serve
+func (s *Session) watchTimeouts(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		reason := s.checkTimeouts()
+		if len(reason) == 0 {
+			continue
+		}
+
+		s.disconnect(reason)
+
+		return
+	}
+}
@@ -0,0 +1,231 @@
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// transfer is a command using the data connection running in the
+// background, it implements cmd.Transfer
+type transfer struct {
+	command string
+	path    string
+	data    cmd.DataConn
+	started time.Time
+
+	// the underlying connection if data is wrapped, closing data
+	// while the command is using it isn't safe
+	conn cmd.DataConn
+
+	aborted   int32
+	abortOnce sync.Once
+
+	// *cmd.TransferStats, set by the command once it completes
+	stats atomic.Value
+
+	// done is closed once the command has finished and replied
+	done chan struct{}
+}
+
+func (t *transfer) Command() string    { return t.command }
+func (t *transfer) Data() cmd.DataConn { return t.data }
+func (t *transfer) Started() time.Time { return t.started }
+func (t *transfer) isAborted() bool    { return atomic.LoadInt32(&t.aborted) == 1 }
+
+// Stats returns how the transfer went, nil until the command has completed
+func (t *transfer) Stats() *cmd.TransferStats {
+	stats, _ := t.stats.Load().(*cmd.TransferStats)
+	return stats
+}
+
+// Abort closes the data connection, causing the command to fail on its next
+// read or write, and waits for it to finish
+func (t *transfer) Abort() {
+	t.abortOnce.Do(func() {
+		atomic.StoreInt32(&t.aborted, 1)
+		t.conn.Close()
+	})
+
+	<-t.done
+}
+
+// asciiCommands transfer file contents, so are translated in TYPE A
+var asciiCommands = map[string]bool{
+	"RETR": true,
+	"STOR": true,
+	"APPE": true,
+}
+
+// throttledCommands send or receive files, so are held to the speed limits.
+// Listings aren't
+var throttledCommands = map[string]bool{
+	"RETR": true,
+	"STOR": true,
+	"APPE": true,
+}
+
+// transferSession gives a transfer its own replies so they don't get mixed up
+// with those of ABOR and STAT on the control goroutine, and the data
+// connection wrapped for this transfer alone
+type transferSession struct {
+	*Session
+	*replyBuffer
+
+	data cmd.DataConn
+}
+
+// Data returns the wrapped data connection of the transfer
+func (ts transferSession) Data() cmd.DataConn { return ts.data }
+
+// ClearData does nothing, the session's data connection is closed and
+// cleared once the transfer has finished
+func (ts transferSession) ClearData() {}
+
+// asciiRestart converts a TYPE A restart position into a file offset. If the
+// file can't be read the position is returned as is and the command can fail
+// on it
+func (s *Session) asciiRestart(path string, position int64) (int64, bool) {
+	reader, _, err := s.FS().DownloadFile(path, acl.SuperUser)
+	if err != nil {
+		return position, false
+	}
+	defer reader.Close()
+
+	buf := s.FS().GetBuffer()
+	defer s.FS().PutBuffer(buf)
+
+	offset, ascii, cr, err := scanASCII(reader, position, *buf)
+	if err != nil {
+		return position, false
+	}
+
+	// past the end, keep it past the end of the file
+	if ascii < position {
+		offset += position - ascii
+	}
+
+	return offset, cr
+}
+
+// Transfer returns the transfer currently running, if any
+func (s *Session) Transfer() cmd.Transfer {
+	if t := s.currentTransfer(); t != nil {
+		return t
+	}
+	return nil
+}
+
+func (s *Session) currentTransfer() *transfer {
+	s.transferMtx.Lock()
+	defer s.transferMtx.Unlock()
+	return s.transfer
+}
+
+// startTransfer runs the command in the background, if it is aborted any reply
+// it made is replaced by a 426. The data connection is wrapped for this
+// transfer only, the session keeps the one from PASV or PORT until the
+// transfer ends, when it is closed and cleared
+func (s *Session) startTransfer(ctx context.Context, ftpCommand string, fields []string) {
+	path := s.FS().Join(s.CWD(), fields[1:])
+
+	data := s.data
+
+	// PRET only announces the next transfer
+	s.pretCommand, s.pretPath = "", ""
+
+	// check who is on the other end before anything else touches the
+	// data connection
+	if p, ok := data.(peerConn); ok {
+		data = newFXPDataConn(data, func() error {
+			return s.checkDataPeer(p, ftpCommand, path)
+		})
+	}
+
+	// the limits wrap the compression, so they count the compressed bytes
+	// that go over the wire
+	var buckets []*bucket
+	if throttledCommands[ftpCommand] {
+		if buckets = s.speedLimits(ftpCommand, path); len(buckets) > 0 {
+			data = newThrottledDataConn(data, buckets)
+		}
+	}
+
+	if s.transferMode == cmd.TransferModeDeflate {
+		data = newDeflateDataConn(data, s.deflateLevel)
+	}
+
+	// TYPE A translation happens before any compression
+	if !s.binaryMode && asciiCommands[ftpCommand] {
+		var cr bool
+
+		// the restart position is an offset into the translated stream, work
+		// out where that is in the file. APPE always appends
+		if s.restartPosition > 0 && ftpCommand != "APPE" {
+			var offset int64
+			offset, cr = s.asciiRestart(path, int64(s.restartPosition))
+			s.restartPosition = int(offset)
+		}
+
+		data = newASCIIDataConn(data, cr && ftpCommand == "RETR")
+	}
+
+	t := &transfer{
+		command: ftpCommand,
+		path:    path,
+		data:    data,
+		conn:    s.data,
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+
+	s.transferMtx.Lock()
+	s.transfer = t
+	s.transferMtx.Unlock()
+
+	ts := transferSession{
+		Session:     s,
+		replyBuffer: &replyBuffer{session: s},
+		data:        data,
+	}
+
+	go func() {
+		defer func() {
+			// the command may have returned without closing it
+			t.conn.Close()
+
+			s.server.releaseBuckets(buckets)
+
+			s.transferMtx.Lock()
+			s.data = nil
+			s.transfer = nil
+			s.transferMtx.Unlock()
+
+			close(t.done)
+		}()
+
+		err := s.handleCommand(ctx, ts, fields)
+
+		if t.isAborted() {
+			ts.discard()
+			ts.ReplyStatus(cmd.StatusDataCloseAborted)
+		}
+
+		if err := ts.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR session flush %s: %s\n", s.RemoteAddr(), err)
+		}
+
+		// end the session by closing the control connection, which stops
+		// the reader in serve
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR handleCommand %s: %s\n", s.RemoteAddr(), err)
+			s.control.Close()
+		}
+	}()
+}
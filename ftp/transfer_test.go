@@ -0,0 +1,66 @@
+package ftp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestTransferAbortRETR(t *testing.T) {
+	ts := newTestServer(t, func(opts *ServerOpts) {
+		// slow enough that the transfer is still running when ABOR arrives
+		opts.DownloadSpeed = 16
+	})
+	ts.createFile(t, "/big.bin", bytes.Repeat([]byte("x"), 1024*1024))
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "TYPE I")
+
+	data := c.pasv()
+	c.cmd(150, "RETR big.bin")
+
+	buf := make([]byte, 1024)
+	if _, err := data.Read(buf); err != nil {
+		t.Fatalf("unexpected error reading data: %s", err)
+	}
+
+	// the transfer replies first, then ABOR
+	c.send("ABOR")
+	c.expect(426)
+	c.expect(226)
+
+	// the session carries on
+	c.cmd(200, "NOOP")
+	c.cmd(425, "RETR big.bin")
+}
+
+func TestTransferAfterFailedRETR(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.createFile(t, "/a.txt", []byte("one\ntwo\n"))
+
+	c := ts.login(t, "alice")
+	c.cmd(200, "TYPE A")
+
+	data := c.pasv()
+	c.cmd(550, "RETR missing.txt")
+	data.Close()
+
+	// the failed transfer used up the data connection
+	c.cmd(425, "RETR a.txt")
+
+	for i := 0; i < 2; i++ {
+		data = c.pasv()
+		c.cmd(150, "RETR a.txt")
+
+		got, err := ioutil.ReadAll(data)
+		if err != nil {
+			t.Fatalf("unexpected error reading data: %s", err)
+		}
+		c.expect(226)
+
+		// translated once, not once for each transfer
+		if expected := "one\r\ntwo\r\n"; string(got) != expected {
+			t.Fatalf("expected %q got %q", expected, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package ftp
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// xferLog appends transfers to a file in the wu-ftpd xferlog format
+type xferLog struct {
+	path string
+
+	f   *os.File
+	mtx sync.Mutex
+}
+
+func openXferLog(path string) (*xferLog, error) {
+	l := xferLog{path: path}
+
+	if err := l.Reopen(); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// Reopen closes the file and opens path again, for log rotation
+func (l *xferLog) Reopen() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("xferlog: %w", err)
+	}
+
+	l.mtx.Lock()
+	old := l.f
+	l.f = f
+	l.mtx.Unlock()
+
+	if old != nil {
+		return old.Close()
+	}
+
+	return nil
+}
+
+// Close stops logging, any later writes fail
+func (l *xferLog) Close() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+
+	err := l.f.Close()
+	l.f = nil
+
+	return err
+}
+
+func (l *xferLog) write(line string) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.f == nil {
+		return os.ErrClosed
+	}
+
+	_, err := l.f.WriteString(line)
+
+	return err
+}
+
+// formatXferLog returns the line for a transfer:
+//
+//	current-time transfer-time remote-host file-size filename transfer-type
+//	special-action-flag direction access-mode username service-name
+//	authentication-method authenticated-user-id completion-status
+//
+// the transfer time is rounded to the nearest second and is at least one,
+// so speeds worked out from it don't divide by zero. Spaces in the filename
+// are replaced with underscores as wu-ftpd does. A bouncer's ident from IDNT
+// is logged as RFC931 authentication
+func formatXferLog(now time.Time, t *cmd.TransferStats, host, user, ident string) string {
+	seconds := int64(math.Round(t.Duration.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	transferType := "a"
+	if t.Binary {
+		transferType = "b"
+	}
+
+	direction := "i"
+	if t.Command == "RETR" {
+		direction = "o"
+	}
+
+	authMethod, authUser := 0, "*"
+	if len(ident) > 0 {
+		authMethod, authUser = 1, ident
+	}
+
+	status := "i"
+	if t.Complete {
+		status = "c"
+	}
+
+	return fmt.Sprintf(
+		"%s %d %s %d %s %s _ %s r %s ftp %d %s %s\n",
+		now.Format("Mon Jan _2 15:04:05 2006"),
+		seconds,
+		host,
+		t.Bytes,
+		strings.ReplaceAll(t.Path, " ", "_"),
+		transferType,
+		direction,
+		user,
+		authMethod,
+		authUser,
+		status,
+	)
+}
+
+// transferLog returns the current xferlog, nil when there isn't one
+func (s *Server) transferLog() *xferLog {
+	s.optsMtx.RLock()
+	defer s.optsMtx.RUnlock()
+	return s.xferLog
+}
+
+// ReopenTransferLog reopens the xferlog after it has been rotated
+func (s *Server) ReopenTransferLog() error {
+	if l := s.transferLog(); l != nil {
+		return l.Reopen()
+	}
+	return nil
+}
+
+// logTransfer writes the session's transfer to the xferlog if there is one
+func (s *Server) logTransfer(session *Session, t *cmd.TransferStats) {
+	l := s.transferLog()
+	if l == nil {
+		return
+	}
+
+	host := session.RemoteAddr().String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	line := formatXferLog(time.Now(), t, host, session.Login(), session.Ident())
+
+	if err := l.write(line); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR xferlog %s: %s\n", l.path, err)
+	}
+}
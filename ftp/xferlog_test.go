@@ -0,0 +1,179 @@
+package ftp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+func TestFormatXferLog(t *testing.T) {
+	now := time.Date(2020, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		stats    cmd.TransferStats
+		ident    string
+		expected string
+	}{
+		{
+			"download",
+			cmd.TransferStats{
+				Command:  "RETR",
+				Path:     "/a.bin",
+				Bytes:    1024,
+				Duration: 3400 * time.Millisecond,
+				Complete: true,
+				Binary:   true,
+			},
+			"",
+			"Wed Mar  4 05:06:07 2020 3 127.0.0.1 1024 /a.bin b _ o r alice ftp 0 * c\n",
+		},
+		{
+			"upload",
+			cmd.TransferStats{
+				Command:  "STOR",
+				Path:     "/a.txt",
+				Bytes:    10,
+				Duration: 2600 * time.Millisecond,
+				Complete: true,
+			},
+			"",
+			"Wed Mar  4 05:06:07 2020 3 127.0.0.1 10 /a.txt a _ i r alice ftp 0 * c\n",
+		},
+		{
+			"append incomplete",
+			cmd.TransferStats{
+				Command:  "APPE",
+				Path:     "/a.txt",
+				Bytes:    5,
+				Duration: 2 * time.Second,
+				Binary:   true,
+			},
+			"",
+			"Wed Mar  4 05:06:07 2020 2 127.0.0.1 5 /a.txt b _ i r alice ftp 0 * i\n",
+		},
+		{
+			"spaces",
+			cmd.TransferStats{
+				Command:  "RETR",
+				Path:     "/some dir/a file.bin",
+				Bytes:    1,
+				Duration: time.Second,
+				Complete: true,
+				Binary:   true,
+			},
+			"",
+			"Wed Mar  4 05:06:07 2020 1 127.0.0.1 1 /some_dir/a_file.bin b _ o r alice ftp 0 * c\n",
+		},
+		{
+			"ident",
+			cmd.TransferStats{
+				Command:  "RETR",
+				Path:     "/a.bin",
+				Bytes:    1,
+				Duration: time.Second,
+				Complete: true,
+				Binary:   true,
+			},
+			"bob",
+			"Wed Mar  4 05:06:07 2020 1 127.0.0.1 1 /a.bin b _ o r alice ftp 1 bob c\n",
+		},
+		{
+			"under a second",
+			cmd.TransferStats{
+				Command:  "RETR",
+				Path:     "/a.bin",
+				Bytes:    1,
+				Duration: 10 * time.Millisecond,
+				Complete: true,
+				Binary:   true,
+			},
+			"",
+			"Wed Mar  4 05:06:07 2020 1 127.0.0.1 1 /a.bin b _ o r alice ftp 0 * c\n",
+		},
+		{
+			"zero",
+			cmd.TransferStats{
+				Command:  "RETR",
+				Path:     "/a.bin",
+				Complete: true,
+				Binary:   true,
+			},
+			"",
+			"Wed Mar  4 05:06:07 2020 1 127.0.0.1 0 /a.bin b _ o r alice ftp 0 * c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatXferLog(now, &tt.stats, "127.0.0.1", "alice", tt.ident)
+			if got != tt.expected {
+				t.Fatalf("expected %q got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestXferLogReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xferlog")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "xferlog")
+
+	l, err := openXferLog(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening: %s", err)
+	}
+	defer l.Close()
+
+	if err := l.write("one\n"); err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+
+	// rotated, the old file still gets writes until reopened
+	rotated := path + ".1"
+	if err := os.Rename(path, rotated); err != nil {
+		t.Fatalf("unexpected error renaming: %s", err)
+	}
+
+	if err := l.write("two\n"); err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+
+	if err := l.Reopen(); err != nil {
+		t.Fatalf("unexpected error reopening: %s", err)
+	}
+
+	if err := l.write("three\n"); err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+
+	for p, expected := range map[string]string{
+		rotated: "one\ntwo\n",
+		path:    "three\n",
+	} {
+		got, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatalf("unexpected error reading %s: %s", p, err)
+		}
+
+		if string(got) != expected {
+			t.Errorf("%s: expected %q got %q", p, expected, got)
+		}
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %s", err)
+	}
+
+	if err := l.write("four\n"); err != os.ErrClosed {
+		t.Fatalf("expected %s writing after close got %v", os.ErrClosed, err)
+	}
+}
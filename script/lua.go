@@ -132,6 +132,21 @@ func (le *LUAEngine) compileFile(path string) error {
 	return nil
 }
 
+// transferStats are given to post hooks of RETR, STOR and APPE, nil if the
+// command didn't complete
+func transferStats(session cmd.Session, hook ScriptHook) *cmd.TransferStats {
+	if hook != ScriptHookPost {
+		return nil
+	}
+
+	t := session.Transfer()
+	if t == nil {
+		return nil
+	}
+
+	return t.Stats()
+}
+
 // Do takes in a context path to the script and a cmd.Session and tries to execute the
 // script
 func (le *LUAEngine) Do(pctx context.Context, fields []string, hook ScriptHook, session cmd.Session) error {
@@ -213,6 +228,7 @@ func (le *LUAEngine) Do(pctx context.Context, fields []string, hook ScriptHook,
 				L.SetGlobal("params", luar.New(L, fields))
 				L.SetGlobal("session", luar.New(L, session))
 				L.SetGlobal("acl", luar.New(L, c.ACL))
+				L.SetGlobal("transfer", luar.New(L, transferStats(session, hook)))
 
 				if err := L.PCall(0, 1, nil); err != nil {
 					return err
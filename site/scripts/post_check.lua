@@ -10,6 +10,11 @@
 -- import lua lib filepath for some nice helpers
 local filepath = require("filepath")
 
+-- transfer is only set once the upload has completed
+if not transfer then
+    return true
+end
+
 local path = session:FS():Join(session:CWD(), params)
 local absolutepath = session:FS():JoinRoot(session:CWD(), params)
 
@@ -23,9 +28,10 @@ end
 local user = session:User()
 
 -- Usage: site/bin/zipscript-c <absolute filepath> <crc> <user> <group> <tagline> <speed> <section>
+-- speed is the average in KB/s
 -- echo $? to get the return code
--- TODO insert tagline, section and a speed (wtf unit is speed)
-local cmd = string.format('site/bin/zipscript-c "%s" "%s" "%s" "%s" "tagline" 10000 "section"; echo $?', absolutepath, entry:CRCHex(), user.Name, user.PrimaryGroup)
+-- TODO insert tagline and section
+local cmd = string.format('site/bin/zipscript-c "%s" "%s" "%s" "%s" "tagline" %d "section"; echo $?', absolutepath, entry:CRCHex(), user.Name, user.PrimaryGroup, math.floor(transfer:KBps()))
 
 print(cmd)
 
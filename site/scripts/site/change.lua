@@ -24,6 +24,24 @@ local err = session:Auth():UpdateUser(target.Name, function(u)
 	if field == "ratio" then
 		u.Ratio = tonumber(params[3])
 
+	elseif field == "logins" then
+		u.Logins = tonumber(params[3])
+
+	elseif field == "uploads" then
+		u.Uploads = tonumber(params[3])
+
+	elseif field == "downloads" then
+		u.Downloads = tonumber(params[3])
+
+	elseif field == "download_speed" then
+		u.DownloadSpeed = tonumber(params[3])
+
+	elseif field == "upload_speed" then
+		u.UploadSpeed = tonumber(params[3])
+
+	elseif field == "idle" then
+		u.IdleTimeout = tonumber(params[3])
+
 	else
 		err = Error()
 		err.Message = "Unknown field"
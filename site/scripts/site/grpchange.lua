@@ -27,6 +27,21 @@ local err = session:Auth():UpdateGroup(target.Name, function(g)
 	elseif field == "leech_slots" then
 		g.LeechSlots = tonumber(params[3])
 
+	elseif field == "logins" then
+		g.Logins = tonumber(params[3])
+
+	elseif field == "uploads" then
+		g.Uploads = tonumber(params[3])
+
+	elseif field == "downloads" then
+		g.Downloads = tonumber(params[3])
+
+	elseif field == "download_speed" then
+		g.DownloadSpeed = tonumber(params[3])
+
+	elseif field == "upload_speed" then
+		g.UploadSpeed = tonumber(params[3])
+
 	else
 		err = Error()
 		err.Message = "Unknown field"
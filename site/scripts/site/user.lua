@@ -17,6 +17,12 @@ end
 session:Reply(226, "User: " .. target.Name)
 session:Reply(226, "Credits: " .. target.Credits / 1024 .. "MB")
 session:Reply(226, "Ratio: 1:" .. target.Ratio)
+session:Reply(226, "Logins: " .. target.Logins)
+session:Reply(226, "Uploads: " .. target.Uploads)
+session:Reply(226, "Downloads: " .. target.Downloads)
+session:Reply(226, "Download Speed: " .. target.DownloadSpeed .. "KB/s")
+session:Reply(226, "Upload Speed: " .. target.UploadSpeed .. "KB/s")
+session:Reply(226, "Idle: " .. target.IdleTimeout .. "s")
 session:Reply(226, "Added By: " .. target.AddedBy)
 session:Reply(226, "Created: " .. target.CreatedAt:Format("15:04 02/01/2006"))
 session:Reply(226, "Last Login: " .. target.LastLoginAt:Format("15:04 02/01/2006"))
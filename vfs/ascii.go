@@ -0,0 +1,74 @@
+package vfs
+
+import (
+	"io"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+// asciiCounter counts the bytes written as they would be sent in TYPE A,
+// where a LF that isn't already part of a CRLF is sent as CRLF. It matches
+// the translation made by the ftp package
+type asciiCounter struct {
+	n    int64
+	prev byte
+}
+
+func (c *asciiCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' && c.prev != '\r' {
+			c.n++
+		}
+		c.n++
+		c.prev = b
+	}
+	return len(p), nil
+}
+
+// ASCIISize checks to see if the user has permission to download the file and then returns
+// its size as it would be sent in TYPE A. Working it out means reading the whole file, so
+// the result is stored in the shadow Entry and used while the Entry is still valid.
+func (fs *Filesystem) ASCIISize(path string, user *acl.User) (int64, error) {
+	reader, _, err := fs.DownloadFile(path, user)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	finfo, err := fs.chroot.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if finfo.IsDir() {
+		return 0, errors.New("is dir")
+	}
+
+	// ignore the error, we can just count the file
+	entry, _ := fs.shadow.Get(path)
+	if entry != nil && entry.valid(finfo) && (entry.ASCIISize > 0 || entry.Size == 0) {
+		return entry.ASCIISize, nil
+	}
+
+	buf := fs.GetBuffer()
+	defer fs.PutBuffer(buf)
+
+	var c asciiCounter
+	if _, err := io.CopyBuffer(&c, reader, *buf); err != nil {
+		return 0, err
+	}
+
+	if entry == nil {
+		return c.n, nil
+	}
+
+	entry.refresh(finfo)
+	entry.ASCIISize = c.n
+
+	if err := fs.shadow.Set(path, entry); err != nil {
+		return 0, err
+	}
+
+	return c.n, nil
+}
@@ -0,0 +1,94 @@
+package vfs
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestASCIISize(t *testing.T) {
+	var tests = []struct {
+		content  string
+		expected int64
+	}{
+		{"", 0},
+		{"HELLO", 5},
+		{"HELLO\nWORLD\n", 14},
+		{"HELLO\r\nWORLD\n", 14},
+		{"\r\r\n\n", 5},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newOSFilesystem(t, []string{"download /** *"})
+				defer stopMemoryFilesystem(t, fs)
+
+				user := newTestUser("user", "nobody")
+
+				createFile(t, fs, "/file", tt.content)
+				setShadowOwner(t, fs, "/file", user)
+
+				size, err := fs.ASCIISize("/file", user)
+				if err != nil {
+					t.Fatalf("unexpected err in ASCIISize: %s", err)
+				}
+
+				if size != tt.expected {
+					t.Errorf("expected %d got %d", tt.expected, size)
+				}
+
+				entry, err := fs.shadow.Get("/file")
+				if err != nil {
+					t.Fatalf("unexpected err in shadow.Get: %s", err)
+				}
+
+				if entry.ASCIISize != tt.expected {
+					t.Errorf("expected %d stored got %d", tt.expected, entry.ASCIISize)
+				}
+			},
+		)
+	}
+}
+
+func TestASCIISizeCached(t *testing.T) {
+	fs := newOSFilesystem(t, []string{"download /** *"})
+	defer stopMemoryFilesystem(t, fs)
+
+	user := newTestUser("user", "nobody")
+
+	createFile(t, fs, "/file", "HELLO\n")
+	setShadowOwner(t, fs, "/file", user)
+
+	if _, err := fs.ASCIISize("/file", user); err != nil {
+		t.Fatalf("unexpected err in ASCIISize: %s", err)
+	}
+
+	// a stored size is used while the entry describes the file
+	entry, err := fs.shadow.Get("/file")
+	if err != nil {
+		t.Fatalf("unexpected err in shadow.Get: %s", err)
+	}
+
+	entry.ASCIISize = 99
+	if err := fs.shadow.Set("/file", entry); err != nil {
+		t.Fatalf("unexpected err in shadow.Set: %s", err)
+	}
+
+	if size, _ := fs.ASCIISize("/file", user); size != 99 {
+		t.Errorf("expected the stored size got %d", size)
+	}
+
+	// and counted again once the file changes
+	f, err := fs.chroot.OpenFile("/file", os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatalf("unexpected err opening file: %s", err)
+	}
+	fmt.Fprint(f, "WORLD\n")
+	f.Close()
+
+	if size, _ := fs.ASCIISize("/file", user); size != 14 {
+		t.Errorf("expected 14 got %d", size)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // File represents objects in the filesytem, is essentially an os.FileInfo with shadow details
@@ -30,15 +31,22 @@ func (flist FileList) Short() []byte {
 }
 
 // Detailed returns a string that lists the collection of files with extra
-// detail, one per line
+// detail, one per line. Like ls, entries older than six months show the year
+// instead of the time
 func (flist FileList) Detailed() []byte {
+	sixMonthsAgo := time.Now().AddDate(0, -6, 0)
+
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf, "total %d\n", len(flist))
 	for _, file := range flist {
 		fmt.Fprint(&buf, file.Mode().String())
 		fmt.Fprintf(&buf, " 1 %s %s ", file.Owner, file.Group)
 		fmt.Fprint(&buf, lpad(strconv.FormatInt(file.Size(), 10), 12))
-		fmt.Fprint(&buf, file.ModTime().Format(" Jan _2 15:04 "))
+		if file.ModTime().Before(sixMonthsAgo) {
+			fmt.Fprint(&buf, file.ModTime().Format(" Jan _2  2006 "))
+		} else {
+			fmt.Fprint(&buf, file.ModTime().Format(" Jan _2 15:04 "))
+		}
 		fmt.Fprintf(&buf, "%s\n", file.Name())
 	}
 	return buf.Bytes()
@@ -50,6 +58,33 @@ func (flist FileList) SortByName() {
 	})
 }
 
+// SortByTime sorts newest first, ties are sorted by name
+func (flist FileList) SortByTime() {
+	sort.SliceStable(flist, func(i, j int) bool {
+		if flist[i].ModTime().Equal(flist[j].ModTime()) {
+			return flist[i].Name() < flist[j].Name()
+		}
+		return flist[i].ModTime().After(flist[j].ModTime())
+	})
+}
+
+// SortBySize sorts largest first, ties are sorted by name
+func (flist FileList) SortBySize() {
+	sort.SliceStable(flist, func(i, j int) bool {
+		if flist[i].Size() == flist[j].Size() {
+			return flist[i].Name() < flist[j].Name()
+		}
+		return flist[i].Size() > flist[j].Size()
+	})
+}
+
+// Reverse reverses the current order
+func (flist FileList) Reverse() {
+	for i, j := 0, len(flist)-1; i < j; i, j = i+1, j-1 {
+		flist[i], flist[j] = flist[j], flist[i]
+	}
+}
+
 func lpad(input string, length int) (result string) {
 	if len(input) < length {
 		result = strings.Repeat(" ", length-len(input)) + input
@@ -0,0 +1,88 @@
+package vfs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (f testFileInfo) Name() string       { return f.name }
+func (f testFileInfo) Size() int64        { return f.size }
+func (f testFileInfo) Mode() os.FileMode  { return 0666 }
+func (f testFileInfo) ModTime() time.Time { return f.modTime }
+func (f testFileInfo) IsDir() bool        { return false }
+func (f testFileInfo) Sys() interface{}   { return nil }
+
+func newTestFileList() FileList {
+	now := time.Now()
+
+	return FileList{
+		{FileInfo: testFileInfo{"b", 10, now.Add(-time.Hour)}},
+		{FileInfo: testFileInfo{"c", 30, now.Add(-time.Minute)}},
+		{FileInfo: testFileInfo{"a", 20, now.Add(-time.Hour * 2)}},
+		{FileInfo: testFileInfo{"d", 20, now.Add(-time.Hour * 2)}},
+	}
+}
+
+func fileListNames(flist FileList) string {
+	var names []string
+	for _, f := range flist {
+		names = append(names, f.Name())
+	}
+	return strings.Join(names, "")
+}
+
+func TestFileListSort(t *testing.T) {
+	var tests = []struct {
+		sort     func(FileList)
+		expected string
+	}{
+		{func(f FileList) { f.SortByName() }, "abcd"},
+		{func(f FileList) { f.SortByTime() }, "cbad"},
+		{func(f FileList) { f.SortBySize() }, "cadb"},
+		{func(f FileList) { f.SortByName(); f.Reverse() }, "dcba"},
+		{func(f FileList) { f.SortByTime(); f.Reverse() }, "dabc"},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				flist := newTestFileList()
+				tt.sort(flist)
+
+				if got := fileListNames(flist); got != tt.expected {
+					t.Errorf("expected '%s' got '%s'", tt.expected, got)
+				}
+			},
+		)
+	}
+}
+
+func TestFileListDetailedYear(t *testing.T) {
+	now := time.Now()
+	old := now.AddDate(-1, 0, 0)
+
+	flist := FileList{
+		{FileInfo: testFileInfo{"new", 1, now}, Owner: "user", Group: "group"},
+		{FileInfo: testFileInfo{"old", 1, old}, Owner: "user", Group: "group"},
+	}
+
+	expected := fmt.Sprintf(
+		"total 2\n-rw-rw-rw- 1 user group            1 %s new\n-rw-rw-rw- 1 user group            1 %s old\n",
+		now.Format("Jan _2 15:04"),
+		old.Format("Jan _2  2006"),
+	)
+
+	if got := string(flist.Detailed()); got != expected {
+		t.Errorf("unexpected detailed:\n%s\nexpected:\n%s", got, expected)
+	}
+}
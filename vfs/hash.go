@@ -0,0 +1,203 @@
+package vfs
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+// supported hash algorithms, named as they are in the HASH draft
+const (
+	HashCRC32  = "CRC32"
+	HashMD5    = "MD5"
+	HashSHA1   = "SHA-1"
+	HashSHA256 = "SHA-256"
+	HashSHA512 = "SHA-512"
+)
+
+// HashAlgorithms lists the supported algorithms
+var HashAlgorithms = []string{
+	HashSHA1,
+	HashSHA256,
+	HashSHA512,
+	HashMD5,
+	HashCRC32,
+}
+
+var ErrUnknownHash = errors.New("unknown hash algorithm")
+
+// newHash returns a new hash.Hash for the algorithm (case insensitive)
+func newHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToUpper(algorithm) {
+	case HashCRC32:
+		return crc32.NewIEEE(), nil
+	case HashMD5:
+		return md5.New(), nil
+	case HashSHA1:
+		return sha1.New(), nil
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	}
+
+	return nil, ErrUnknownHash
+}
+
+// resumeCRC is a crc32 hash.Hash32 that can carry on from a previous
+// checksum, which hash/crc32 does not expose
+type resumeCRC struct {
+	crc uint32
+}
+
+func (r *resumeCRC) Write(p []byte) (int, error) {
+	r.crc = crc32.Update(r.crc, crc32.IEEETable, p)
+	return len(p), nil
+}
+
+func (r *resumeCRC) Sum(b []byte) []byte {
+	return append(b, byte(r.crc>>24), byte(r.crc>>16), byte(r.crc>>8), byte(r.crc))
+}
+
+func (r *resumeCRC) Sum32() uint32  { return r.crc }
+func (r *resumeCRC) Reset()         { r.crc = 0 }
+func (r *resumeCRC) Size() int      { return crc32.Size }
+func (r *resumeCRC) BlockSize() int { return 1 }
+
+// valid checks to see if the hashes stored in the Entry still describe
+// the file, it has to be the size and have the modification time it had
+// when they were computed. UpdatedAt can't be used as any change to the
+// Entry, e.g. a chown, moves it on
+func (e *Entry) valid(finfo os.FileInfo) bool {
+	return e.Size == finfo.Size() && e.ModTime.Equal(finfo.ModTime())
+}
+
+// stamp records the file the hashes are being computed for
+func (e *Entry) stamp(finfo os.FileInfo) {
+	e.Size = finfo.Size()
+	e.ModTime = finfo.ModTime()
+}
+
+// refresh clears everything worked out from the file's contents if the Entry
+// no longer describes it
+func (e *Entry) refresh(finfo os.FileInfo) {
+	if e.valid(finfo) {
+		return
+	}
+
+	e.CRC = 0
+	e.SHA256 = nil
+	e.ASCIISize = 0
+	e.stamp(finfo)
+}
+
+// sum returns the stored hash for the algorithm if we have one
+func (e *Entry) sum(algorithm string) []byte {
+	switch strings.ToUpper(algorithm) {
+	case HashCRC32:
+		// a zero crc is only valid for an empty file
+		if e.CRC == 0 && e.Size > 0 {
+			return nil
+		}
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, e.CRC)
+		return b
+
+	case HashSHA256:
+		return e.SHA256
+	}
+
+	return nil
+}
+
+// HashFile checks to see if the user has permission to download the file and then returns
+// the hash of the bytes from start up to end. An end of 0 means the end of the file. If the
+// whole file is requested and the shadow Entry is still valid the stored hash is used,
+// otherwise the file is hashed and, where possible, the result stored.
+func (fs *Filesystem) HashFile(path, algorithm string, start, end int64, user *acl.User) ([]byte, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, size, err := fs.DownloadFile(path, user)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	finfo, err := fs.chroot.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if finfo.IsDir() {
+		return nil, errors.New("is dir")
+	}
+
+	if end == 0 || end > size {
+		end = size
+	}
+
+	if start < 0 || start > end {
+		return nil, errors.New("invalid range")
+	}
+
+	whole := start == 0 && end == size
+
+	var entry *Entry
+	if whole {
+		// ignore the error, we can just hash the file
+		entry, _ = fs.shadow.Get(path)
+		if entry != nil && entry.valid(finfo) {
+			if sum := entry.sum(algorithm); sum != nil {
+				return sum, nil
+			}
+		}
+	}
+
+	if _, err := reader.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf := fs.GetBuffer()
+	defer fs.PutBuffer(buf)
+
+	if _, err := io.CopyBuffer(h, io.LimitReader(reader, end-start), *buf); err != nil {
+		return nil, err
+	}
+
+	sum := h.Sum(nil)
+
+	if entry == nil {
+		return sum, nil
+	}
+
+	// store it for next time, anything else we had is now stale
+	entry.refresh(finfo)
+
+	switch strings.ToUpper(algorithm) {
+	case HashCRC32:
+		entry.CRC = binary.BigEndian.Uint32(sum)
+	case HashSHA256:
+		entry.SHA256 = sum
+	default:
+		return sum, nil
+	}
+
+	if err := fs.shadow.Set(path, entry); err != nil {
+		return nil, err
+	}
+
+	return sum, nil
+}
@@ -0,0 +1,379 @@
+package vfs
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+func TestHashFile(t *testing.T) {
+	content := "HELLO WORLD"
+
+	crc := make([]byte, 4)
+	sum32 := crc32.ChecksumIEEE([]byte(content))
+	crc[0], crc[1], crc[2], crc[3] = byte(sum32>>24), byte(sum32>>16), byte(sum32>>8), byte(sum32)
+
+	sha := sha256.Sum256([]byte(content))
+	shaWorld := sha256.Sum256([]byte("WORLD"))
+	md := md5.Sum([]byte(content))
+
+	var tests = []struct {
+		rules     []string
+		algorithm string
+		start     int64
+		end       int64
+		expected  []byte
+		err       error
+	}{
+		{
+			[]string{"download /** *"},
+			HashCRC32,
+			0,
+			0,
+			crc,
+			nil,
+		},
+		{
+			[]string{"download /** *"},
+			"sha-256",
+			0,
+			0,
+			sha[:],
+			nil,
+		},
+		{
+			[]string{"download /** *"},
+			HashSHA256,
+			6,
+			11,
+			shaWorld[:],
+			nil,
+		},
+		{
+			[]string{"download /** *"},
+			HashMD5,
+			0,
+			0,
+			md[:],
+			nil,
+		},
+		{
+			[]string{"download /** *"},
+			"whirlpool",
+			0,
+			0,
+			nil,
+			ErrUnknownHash,
+		},
+		{
+			[]string{"download /** *"},
+			HashMD5,
+			8,
+			4,
+			nil,
+			errors.New("invalid range"),
+		},
+		{
+			[]string{"download /** !*"},
+			HashCRC32,
+			0,
+			0,
+			nil,
+			acl.ErrPermissionDenied,
+		},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newMemoryFilesystem(t, tt.rules)
+				if fs == nil {
+					t.Fatal("unexpected nil for fs")
+				}
+				defer stopMemoryFilesystem(t, fs)
+
+				user := newTestUser("user", "group")
+
+				createFile(t, fs, "/file", content)
+				setShadowOwner(t, fs, "/file", user)
+
+				got, err := fs.HashFile("/file", tt.algorithm, tt.start, tt.end, user)
+				checkErr(t, err, tt.err)
+
+				if tt.err == nil && string(got) != string(tt.expected) {
+					t.Errorf("expected hash to be '%x' got: '%x'", tt.expected, got)
+				}
+			},
+		)
+	}
+}
+
+func TestHashFileUsesShadow(t *testing.T) {
+	// memfs always reports the current time as the mod time
+	fs := newOSFilesystem(t, []string{"download /** *", "upload /** *"})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	fs.UploadSHA256 = true
+
+	user := newTestUser("user", "group")
+
+	writer, err := fs.UploadFile("/file", user)
+	if err != nil {
+		t.Fatalf("unexpected err in UploadFile: %s", err)
+	}
+
+	fmt.Fprint(writer, "HELLO")
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unexpected err in close: %s", err)
+	}
+
+	entry, err := fs.shadow.Get("/file")
+	if err != nil {
+		t.Fatalf("unexpected err in shadow.Get: %s", err)
+	}
+
+	expected := sha256.Sum256([]byte("HELLO"))
+
+	if string(entry.SHA256) != string(expected[:]) {
+		t.Fatalf("expected shadow sha256 to be '%x' got: '%x'", expected, entry.SHA256)
+	}
+
+	if entry.Size != 5 {
+		t.Fatalf("expected shadow size to be 5 got: %d", entry.Size)
+	}
+
+	// poison the stored hash, if it is returned then we used the shadow
+	entry.SHA256 = []byte("from shadow")
+	if err := fs.shadow.Set("/file", entry); err != nil {
+		t.Fatalf("unexpected err in shadow.Set: %s", err)
+	}
+
+	got, err := fs.HashFile("/file", HashSHA256, 0, 0, user)
+	checkErr(t, err, nil)
+
+	if string(got) != "from shadow" {
+		t.Errorf("expected hash from shadow got: '%x'", got)
+	}
+
+	// a different size means the stored hashes are stale and should be
+	// recomputed and stored
+	entry.Size = 100
+	if err := fs.shadow.Set("/file", entry); err != nil {
+		t.Fatalf("unexpected err in shadow.Set: %s", err)
+	}
+
+	got, err = fs.HashFile("/file", HashSHA256, 0, 0, user)
+	checkErr(t, err, nil)
+
+	if string(got) != string(expected[:]) {
+		t.Errorf("expected hash to be '%x' got: '%x'", expected, got)
+	}
+
+	entry, err = fs.shadow.Get("/file")
+	if err != nil {
+		t.Fatalf("unexpected err in shadow.Get: %s", err)
+	}
+
+	if string(entry.SHA256) != string(expected[:]) || entry.Size != 5 || entry.CRC != 0 {
+		t.Errorf("expected shadow to be updated got: %+v", entry)
+	}
+}
+
+func TestHashFileRewrittenSameSize(t *testing.T) {
+	fs := newOSFilesystem(t, []string{"download /** *", "upload /** *"})
+	defer stopMemoryFilesystem(t, fs)
+
+	user := newTestUser("user", "group")
+
+	writer, err := fs.UploadFile("/file", user)
+	if err != nil {
+		t.Fatalf("unexpected err in UploadFile: %s", err)
+	}
+
+	fmt.Fprint(writer, "HELLO")
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unexpected err in close: %s", err)
+	}
+
+	// rewritten outside of the vfs to the same size
+	f, err := fs.chroot.OpenFile("/file", os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		t.Fatalf("unexpected err opening file: %s", err)
+	}
+	fmt.Fprint(f, "JELLO")
+	f.Close()
+
+	if err := fs.chtimes("/file", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("unexpected err in chtimes: %s", err)
+	}
+
+	// then something unrelated, e.g. a chown, updates the entry
+	entry, err := fs.shadow.Get("/file")
+	if err != nil {
+		t.Fatalf("unexpected err in shadow.Get: %s", err)
+	}
+
+	entry.User = "other"
+	if err := fs.shadow.Set("/file", entry); err != nil {
+		t.Fatalf("unexpected err in shadow.Set: %s", err)
+	}
+
+	got, err := fs.HashFile("/file", HashCRC32, 0, 0, user)
+	checkErr(t, err, nil)
+
+	if expected := crc32.ChecksumIEEE([]byte("JELLO")); binary.BigEndian.Uint32(got) != expected {
+		t.Errorf("expected crc of 'JELLO' got: %x", got)
+	}
+}
+
+func TestResumeUploadFileCRC(t *testing.T) {
+	var tests = []struct {
+		content string
+		offset  int64
+		resume  string
+		shadow  bool
+		err     error
+	}{
+		{"HELLO WORLD", 5, " THERE", false, nil},
+		{"HELLO WORLD", 11, "!", false, nil},
+		{"HELLO WORLD", 11, "!", true, nil},
+		{"HELLO WORLD", 0, "BYE", false, nil},
+		{"HELLO WORLD", 12, "!", false, errors.New("restart position is beyond the end of the file")},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newOSFilesystem(t, []string{"resume /** *", "upload /** *"})
+				defer stopMemoryFilesystem(t, fs)
+
+				user := newTestUser("user", "nobody")
+
+				if tt.shadow {
+					writer, err := fs.UploadFile("/file", user)
+					if err != nil {
+						t.Fatalf("unexpected err in UploadFile: %s", err)
+					}
+					fmt.Fprint(writer, tt.content)
+					if err := writer.Close(); err != nil {
+						t.Fatalf("unexpected err in close: %s", err)
+					}
+				} else {
+					createFile(t, fs, "/file", tt.content)
+					setShadowOwner(t, fs, "/file", user)
+				}
+
+				writer, err := fs.ResumeUploadFile("/file", tt.offset, user)
+				checkErr(t, err, tt.err)
+
+				if tt.err != nil {
+					return
+				}
+
+				fmt.Fprint(writer, tt.resume)
+
+				if err := writer.Close(); err != nil {
+					t.Fatalf("unexpected err in close: %s", err)
+				}
+
+				expected := tt.content[:tt.offset] + tt.resume
+
+				entry, err := fs.shadow.Get("/file")
+				if err != nil {
+					t.Fatalf("unexpected err in shadow.Get: %s", err)
+				}
+
+				if entry.CRC != crc32.ChecksumIEEE([]byte(expected)) {
+					t.Errorf("expected crc of '%s' got: %08x", expected, entry.CRC)
+				}
+
+				if entry.Size != int64(len(expected)) {
+					t.Errorf("expected size %d got: %d", len(expected), entry.Size)
+				}
+			},
+		)
+	}
+}
+
+func TestWriteFileAt(t *testing.T) {
+	var tests = []struct {
+		offset   int64
+		write    string
+		expected string
+		err      error
+	}{
+		{0, "J", "JELLO WORLD", nil},
+		{6, "THERE", "HELLO THERE", nil},
+		{6, "THERE!", "HELLO THERE!", nil},
+		{11, "!", "HELLO WORLD!", nil},
+		{12, "!", "", errors.New("range start is beyond the end of the file")},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newOSFilesystem(t, []string{"resume /** *", "upload /** *", "download /** *"})
+				defer stopMemoryFilesystem(t, fs)
+
+				owner := newTestUser("owner", "nobody")
+
+				writer, err := fs.UploadFile("/file", owner)
+				if err != nil {
+					t.Fatalf("unexpected err in UploadFile: %s", err)
+				}
+				fmt.Fprint(writer, "HELLO WORLD")
+				if err := writer.Close(); err != nil {
+					t.Fatalf("unexpected err in close: %s", err)
+				}
+
+				writer, err = fs.WriteFileAt("/file", tt.offset, newTestUser("user", "nobody"))
+				checkErr(t, err, tt.err)
+
+				if tt.err != nil {
+					return
+				}
+
+				fmt.Fprint(writer, tt.write)
+
+				if err := writer.Close(); err != nil {
+					t.Fatalf("unexpected err in close: %s", err)
+				}
+
+				entry, err := fs.shadow.Get("/file")
+				if err != nil {
+					t.Fatalf("unexpected err in shadow.Get: %s", err)
+				}
+
+				if entry.User != owner.Name {
+					t.Errorf("expected owner to be kept got: '%s'", entry.User)
+				}
+
+				// worked out again from what is on disk
+				sum, err := fs.HashFile("/file", HashCRC32, 0, 0, owner)
+				if err != nil {
+					t.Fatalf("unexpected err in HashFile: %s", err)
+				}
+
+				if expected := crc32.ChecksumIEEE([]byte(tt.expected)); binary.BigEndian.Uint32(sum) != expected {
+					t.Errorf("expected crc of '%s' got: %x", tt.expected, sum)
+				}
+			},
+		)
+	}
+}
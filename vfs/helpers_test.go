@@ -6,7 +6,9 @@ import (
 	"testing"
 
 	"github.com/dgraph-io/badger/v2"
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/goftpd/goftpd/acl"
 )
 
@@ -100,6 +102,18 @@ func newMemoryFilesystem(t *testing.T, lines []string) *Filesystem {
 		t.Fatalf("unexpected error creating root path: %s", err)
 	}
 
+	return newTestFilesystem(t, memory, lines)
+}
+
+func newOSFilesystem(t *testing.T, lines []string) *Filesystem {
+	t.Helper()
+
+	return newTestFilesystem(t, osfs.New(t.TempDir()), lines)
+}
+
+func newTestFilesystem(t *testing.T, chroot billy.Filesystem, lines []string) *Filesystem {
+	t.Helper()
+
 	ss := newMemoryShadowStore(t)
 
 	var rules []acl.Rule
@@ -118,7 +132,7 @@ func newMemoryFilesystem(t *testing.T, lines []string) *Filesystem {
 		DefaultGroup: "nogroup",
 	}
 
-	fs, err := NewFilesystem(&opts, memory, ss, perms)
+	fs, err := NewFilesystem(&opts, chroot, ss, perms)
 	if err != nil {
 		t.Fatalf("unexpected error creating NewFilesystem: %s", err)
 	}
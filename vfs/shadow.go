@@ -28,6 +28,18 @@ type Entry struct {
 
 	CRC uint32
 
+	// optionally computed during upload
+	SHA256 []byte
+
+	// size of the file sent in TYPE A, worked out the first time SIZE
+	// asks for it. Like CRC a zero is only valid for an empty file
+	ASCIISize int64
+
+	// size and modification time of the file when the hashes were
+	// computed, see valid
+	Size    int64
+	ModTime time.Time
+
 	// meta
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -1,6 +1,7 @@
 package vfs
 
 import (
+	"crypto/sha256"
 	"hash"
 	"hash/crc32"
 	"io"
@@ -12,6 +13,9 @@ import (
 	"time"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/polyfill"
+	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/goftpd/goftpd/acl"
 	"github.com/pkg/errors"
 )
@@ -36,17 +40,28 @@ type VFS interface {
 	Join(string, []string) string
 	JoinRoot(string, []string) string
 	Stop() error
+	Reload(*FilesystemOpts, *acl.Permissions)
 	MakeDir(string, *acl.User) error
 	DownloadFile(string, *acl.User) (ReadSeekCloser, int64, error)
+	CheckDownload(string, *acl.User) error
 	UploadFile(string, *acl.User) (io.WriteCloser, error)
-	ResumeUploadFile(string, *acl.User) (io.WriteCloser, error)
+	CheckUpload(string, *acl.User) error
+	CheckResume(string, *acl.User) error
+	ResumeUploadFile(string, int64, *acl.User) (io.WriteCloser, error)
+	WriteFileAt(string, int64, *acl.User) (io.WriteCloser, error)
 	RenameFile(string, string, *acl.User) error
 	DeleteFile(string, *acl.User) error
 	DeleteDir(string, *acl.User) error
+	SetModTime(string, time.Time, *acl.User) error
 	ListDir(string, *acl.User) (FileList, error)
+	Stat(string, *acl.User) (FileInfo, error)
+	PermissionFacts(string, bool, *acl.User) string
+	CheckFXP(string, bool, *acl.User) error
 	Size(string) (int64, error)
 
 	GetEntry(string) (*Entry, error)
+	HashFile(string, string, int64, int64, *acl.User) ([]byte, error)
+	ASCIISize(string, *acl.User) (int64, error)
 
 	GetBuffer() *[]byte
 	PutBuffer(*[]byte)
@@ -58,6 +73,7 @@ type FilesystemOpts struct {
 	DefaultUser  string `goftpd:"default_user"`
 	DefaultGroup string `goftpd:"default_group"`
 	Hide         string `goftpd:"hide"`
+	UploadSHA256 bool   `goftpd:"upload_sha256"`
 	hideRE       *regexp.Regexp
 }
 
@@ -65,11 +81,15 @@ func (f *FilesystemOpts) SetHideRE(r *regexp.Regexp) { f.hideRE = r }
 
 type Filesystem struct {
 	*FilesystemOpts
-	chroot      billy.Filesystem
-	shadow      Shadow
+	chroot   billy.Filesystem
+	shadow   Shadow
+	buffPool sync.Pool
+	crcPool  sync.Pool
+
+	// can be swapped by Reload
 	permissions *acl.Permissions
-	buffPool    sync.Pool
-	crcPool     sync.Pool
+	hide        *regexp.Regexp
+	rulesMtx    sync.RWMutex
 
 	cwd string
 }
@@ -87,6 +107,7 @@ func NewFilesystem(opts *FilesystemOpts, chroot billy.Filesystem, shadow Shadow,
 		chroot:         chroot,
 		shadow:         shadow,
 		permissions:    permissions,
+		hide:           opts.hideRE,
 		buffPool:       newBufferPoolWithSize(256 * 1024),
 		crcPool: sync.Pool{
 			New: func() interface{} {
@@ -101,6 +122,31 @@ func NewFilesystem(opts *FilesystemOpts, chroot billy.Filesystem, shadow Shadow,
 
 func (fs *Filesystem) SuperUser() *acl.User { return acl.SuperUser }
 
+// Reload swaps the permissions and the hide regexp from opts, the rest of
+// the options can't be changed while running
+func (fs *Filesystem) Reload(opts *FilesystemOpts, permissions *acl.Permissions) {
+	fs.rulesMtx.Lock()
+	defer fs.rulesMtx.Unlock()
+
+	fs.permissions = permissions
+	fs.hide = opts.hideRE
+}
+
+func (fs *Filesystem) perms() *acl.Permissions {
+	fs.rulesMtx.RLock()
+	defer fs.rulesMtx.RUnlock()
+	return fs.permissions
+}
+
+// hidden checks path against the hide regexp
+func (fs *Filesystem) hidden(path string) bool {
+	fs.rulesMtx.RLock()
+	hide := fs.hide
+	fs.rulesMtx.RUnlock()
+
+	return hide != nil && hide.MatchString(path)
+}
+
 func (fs *Filesystem) GetEntry(path string) (*Entry, error) {
 	return fs.shadow.Get(path)
 }
@@ -114,7 +160,7 @@ func (fs *Filesystem) PutBuffer(b *[]byte) {
 }
 
 // Join tries to give back a safe path
-func (fs Filesystem) Join(current string, params []string) string {
+func (fs *Filesystem) Join(current string, params []string) string {
 
 	path := strings.Join(params, " ")
 
@@ -126,7 +172,7 @@ func (fs Filesystem) Join(current string, params []string) string {
 }
 
 // Join tries to give back a safe path
-func (fs Filesystem) JoinRoot(current string, params []string) string {
+func (fs *Filesystem) JoinRoot(current string, params []string) string {
 
 	path := strings.Join(params, " ")
 
@@ -166,12 +212,12 @@ func (fs *Filesystem) Size(path string) (int64, error) {
 
 // MakeDir checks to see if the user has permission to create a new directory. Does so if allowed
 func (fs *Filesystem) MakeDir(path string, user *acl.User) error {
-	if !fs.permissions.Match(acl.PermissionScopeMakeDir, path, user) {
+	if !fs.perms().Match(acl.PermissionScopeMakeDir, path, user) {
 		return acl.ErrPermissionDenied
 	}
 
 	// check for private
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
 		return acl.ErrPermissionDenied
 	}
 
@@ -205,24 +251,19 @@ func (fs *Filesystem) MakeDir(path string, user *acl.User) error {
 // DownloadFile checks to see if the user has permission to read the file (checking download
 // permissions from high level to low level). Returns an io.ReadCloser if allowed
 func (fs *Filesystem) DownloadFile(path string, user *acl.User) (ReadSeekCloser, int64, error) {
-	if !fs.permissions.Match(acl.PermissionScopeDownload, path, user) {
-		return nil, 0, acl.ErrPermissionDenied
-	}
-
-	// check for private
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
-		return nil, 0, os.ErrNotExist
+	if err := fs.checkDownload(path, user); err != nil {
+		return nil, 0, err
 	}
 
-	if fs.hideRE != nil {
-		if fs.hideRE.MatchString(path) {
-			// do not leak any information, just pretend
-			// it doesnt exist
-			return nil, 0, os.ErrNotExist
-		}
+	// files on disk are opened directly so OSFile can find them, billy
+	// wraps them otherwise
+	var f ReadSeekCloser
+	var err error
+	if realpath, ok := fs.osPath(path); ok {
+		f, err = os.Open(realpath)
+	} else {
+		f, err = fs.chroot.Open(path)
 	}
-
-	f, err := fs.chroot.Open(path)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -232,48 +273,105 @@ func (fs *Filesystem) DownloadFile(path string, user *acl.User) (ReadSeekCloser,
 
 	finfo, err := fs.chroot.Stat(path)
 	if err != nil {
+		f.Close()
 		return nil, 0, err
 	}
 
 	return f, finfo.Size(), nil
 }
 
-// UploadFile checks to see if the user has permission to write the file (checking upload
-// permissions from high level to low level). Returns an io.Writer if allowed. Does not
-// truncate a file
-func (fs *Filesystem) UploadFile(path string, user *acl.User) (io.WriteCloser, error) {
-	// TODO
-	// need to check if we are currently uploading can add this state to the Entry
+// OSFile returns the *os.File behind a file returned by DownloadFile so it
+// can be sent with sendfile. Returns false for files that aren't on disk
+func OSFile(r io.Reader) (*os.File, bool) {
+	f, ok := r.(*os.File)
+	return f, ok && f != nil
+}
 
-	if !fs.permissions.Match(acl.PermissionScopeUpload, path, user) {
-		return nil, acl.ErrPermissionDenied
+// CheckDownload checks to see if the user would be able to download the file without opening
+// it, the same checks are made by DownloadFile
+func (fs *Filesystem) CheckDownload(path string, user *acl.User) error {
+	if err := fs.checkDownload(path, user); err != nil {
+		return err
+	}
+
+	finfo, err := fs.chroot.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if finfo.IsDir() {
+		return errors.New("not a file")
+	}
+
+	return nil
+}
+
+func (fs *Filesystem) checkDownload(path string, user *acl.User) error {
+	if !fs.perms().Match(acl.PermissionScopeDownload, path, user) {
+		return acl.ErrPermissionDenied
 	}
 
 	// check for private
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
-		return nil, acl.ErrPermissionDenied
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+		return os.ErrNotExist
+	}
+
+	if fs.hidden(path) {
+		// do not leak any information, just pretend
+		// it doesnt exist
+		return os.ErrNotExist
+	}
+
+	return nil
+}
+
+// CheckUpload checks to see if the user would be able to upload the file, including
+// overwriting it if it already exists, without creating or truncating it. The same
+// checks are made by UploadFile
+func (fs *Filesystem) CheckUpload(path string, user *acl.User) error {
+	if !fs.perms().Match(acl.PermissionScopeUpload, path, user) {
+		return acl.ErrPermissionDenied
+	}
+
+	// check for private
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+		return acl.ErrPermissionDenied
 	}
 
 	// check if we would be able to delete it
 	if _, err := fs.chroot.Stat(path); err == nil {
-		if !fs.permissions.Match(acl.PermissionScopeDelete, path, user) {
+		if !fs.perms().Match(acl.PermissionScopeDelete, path, user) {
 
 			// not allowed to globally delete, check if this is ours and we can delete our own
-			if !fs.permissions.Match(acl.PermissionScopeDeleteOwn, path, user) {
-				return nil, acl.ErrPermissionDenied
+			if !fs.perms().Match(acl.PermissionScopeDeleteOwn, path, user) {
+				return acl.ErrPermissionDenied
 			}
 
 			owner, err := fs.checkOwnership(path, user)
 			if err != nil {
-				return nil, err
+				return err
 			}
 
 			if !owner {
-				return nil, acl.ErrPermissionDenied
+				return acl.ErrPermissionDenied
 			}
 		}
 	}
 
+	return nil
+}
+
+// UploadFile checks to see if the user has permission to write the file (checking upload
+// permissions from high level to low level). Returns an io.Writer if allowed. Does not
+// truncate a file
+func (fs *Filesystem) UploadFile(path string, user *acl.User) (io.WriteCloser, error) {
+	// TODO
+	// need to check if we are currently uploading can add this state to the Entry
+
+	if err := fs.CheckUpload(path, user); err != nil {
+		return nil, err
+	}
+
 	f, err := fs.chroot.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, defaultPerms)
 	if err != nil {
 		return nil, err
@@ -282,103 +380,217 @@ func (fs *Filesystem) UploadFile(path string, user *acl.User) (io.WriteCloser, e
 	entry := NewEntry(user.Name, user.PrimaryGroup)
 	h := fs.crcPool.Get().(hash.Hash32)
 	h.Reset()
+
+	hashes := []hash.Hash{h}
+
+	var sha hash.Hash
+	if fs.UploadSHA256 {
+		sha = sha256.New()
+		hashes = append(hashes, sha)
+	}
+
 	// wrap the file in our special Writer that allows us to manage the shadow fs
-	writer := newWriteCloser(h, f, func(w *writeCloser) error {
+	writer := newWriteCloser(f, func(w *writeCloser) error {
 		entry.CRC = h.Sum32()
 		fs.crcPool.Put(h)
+
+		if sha != nil {
+			entry.SHA256 = sha.Sum(nil)
+		}
+
+		// record the size so we know if the hashes are still valid
+		if finfo, err := fs.chroot.Stat(path); err == nil {
+			entry.stamp(finfo)
+		}
+
 		return fs.shadow.Set(path, &entry)
-	})
+	}, hashes...)
 
 	return writer, nil
 }
 
 // ResumeUploadFile checks to see if the user has permission to write the file (checking upload
 // permissions from high level to low level). It also checks to see if they have resume writes.
-// Returns an io.Writer if allowed.
-func (fs *Filesystem) ResumeUploadFile(path string, user *acl.User) (io.WriteCloser, error) {
-	if !fs.permissions.Match(acl.PermissionScopeUpload, path, user) {
-		return nil, acl.ErrPermissionDenied
+// Anything after offset is truncated and writing starts from there. The crc stored in the shadow
+// covers the whole file. Returns an io.Writer if allowed.
+func (fs *Filesystem) ResumeUploadFile(path string, offset int64, user *acl.User) (io.WriteCloser, error) {
+	if err := fs.CheckResume(path, user); err != nil {
+		return nil, err
 	}
 
-	// check for private
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
-		return nil, os.ErrNotExist
+	f, err := fs.chroot.OpenFile(path, os.O_RDWR, defaultPerms)
+	if err != nil {
+		return nil, err
 	}
 
-	if fs.hideRE != nil {
-		if fs.hideRE.MatchString(path) {
-			// do not leak any information, just pretend
-			// it doesnt exist
-			return nil, os.ErrNotExist
-		}
+	finfo, err := fs.chroot.Stat(path)
+	if err != nil {
+		f.Close()
+		return nil, err
 	}
 
-	if !fs.permissions.Match(acl.PermissionScopeResume, path, user) {
-		// not allowed to globally resume, check if this is ours and we can resume our own
-		if !fs.permissions.Match(acl.PermissionScopeResumeOwn, path, user) {
-			return nil, acl.ErrPermissionDenied
-		}
+	if offset < 0 || offset > finfo.Size() {
+		f.Close()
+		return nil, errors.New("restart position is beyond the end of the file")
+	}
+
+	// if the shadow still describes the file up to offset we can carry on
+	// from its crc, otherwise hash the part we are keeping
+	h := &resumeCRC{}
+
+	if entry, err := fs.shadow.Get(path); err == nil && entry.Size == offset && entry.valid(finfo) && entry.sum(HashCRC32) != nil {
+		h.crc = entry.CRC
+	} else {
+		buf := fs.GetBuffer()
+		_, err := io.CopyBuffer(h, io.LimitReader(f, offset), *buf)
+		fs.PutBuffer(buf)
 
-		owner, err := fs.checkOwnership(path, user)
 		if err != nil {
+			f.Close()
 			return nil, err
 		}
+	}
 
-		if !owner {
-			return nil, acl.ErrPermissionDenied
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	entry := NewEntry(user.Name, user.PrimaryGroup)
+
+	// wrap the file in our special Writer that allows us to manage the shadow fs
+	writer := newWriteCloser(f, func(w *writeCloser) error {
+		entry.CRC = h.Sum32()
+
+		if finfo, err := fs.chroot.Stat(path); err == nil {
+			entry.stamp(finfo)
 		}
+
+		return fs.shadow.Set(path, &entry)
+	}, h)
+
+	return writer, nil
+}
+
+// WriteFileAt checks the user can resume the file, as ResumeUploadFile does, and returns a
+// writer starting at offset that leaves the rest of the file as it is, for a STOR after RANG.
+// The hashes in the shadow no longer describe the file so are cleared, HashFile works them out
+// again when asked
+func (fs *Filesystem) WriteFileAt(path string, offset int64, user *acl.User) (io.WriteCloser, error) {
+	if err := fs.CheckResume(path, user); err != nil {
+		return nil, err
 	}
 
-	f, err := fs.chroot.OpenFile(path, os.O_RDWR|os.O_APPEND, defaultPerms)
+	f, err := fs.chroot.OpenFile(path, os.O_RDWR, defaultPerms)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := f.Seek(0, os.SEEK_END); err != nil {
+	finfo, err := fs.chroot.Stat(path)
+	if err != nil {
+		f.Close()
 		return nil, err
 	}
 
+	if offset < 0 || offset > finfo.Size() {
+		f.Close()
+		return nil, errors.New("range start is beyond the end of the file")
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// only the contents change, not who owns it
 	entry := NewEntry(user.Name, user.PrimaryGroup)
-	h := fs.crcPool.Get().(hash.Hash32)
-	h.Reset()
-	// wrap the file in our special Writer that allows us to manage the shadow fs
-	writer := newWriteCloser(h, f, func(w *writeCloser) error {
-		entry.CRC = h.Sum32()
-		fs.crcPool.Put(h)
+	if existing, err := fs.shadow.Get(path); err == nil {
+		entry = *existing
+	}
+
+	writer := newWriteCloser(f, func(w *writeCloser) error {
+		entry.CRC = 0
+		entry.SHA256 = nil
+		entry.ASCIISize = 0
+
+		if finfo, err := fs.chroot.Stat(path); err == nil {
+			entry.stamp(finfo)
+		}
+
 		return fs.shadow.Set(path, &entry)
 	})
 
 	return writer, nil
 }
 
+// CheckResume checks the user can upload to the path and resume the file there, either
+// anyone's or only their own. The same checks are made by ResumeUploadFile
+func (fs *Filesystem) CheckResume(path string, user *acl.User) error {
+	if !fs.perms().Match(acl.PermissionScopeUpload, path, user) {
+		return acl.ErrPermissionDenied
+	}
+
+	// check for private
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+		return os.ErrNotExist
+	}
+
+	if fs.hidden(path) {
+		// do not leak any information, just pretend
+		// it doesnt exist
+		return os.ErrNotExist
+	}
+
+	if !fs.perms().Match(acl.PermissionScopeResume, path, user) {
+		// not allowed to globally resume, check if this is ours and we can resume our own
+		if !fs.perms().Match(acl.PermissionScopeResumeOwn, path, user) {
+			return acl.ErrPermissionDenied
+		}
+
+		owner, err := fs.checkOwnership(path, user)
+		if err != nil {
+			return err
+		}
+
+		if !owner {
+			return acl.ErrPermissionDenied
+		}
+	}
+
+	return nil
+}
+
 // RenameFile checks to see if the user has permission to rename the file (checking rename and
 // renameown scopes).
 func (fs *Filesystem) RenameFile(oldpath, newpath string, user *acl.User) error {
 	// make sure that the user has permission to upload to the new path
-	if !fs.permissions.Match(acl.PermissionScopeUpload, newpath, user) {
+	if !fs.perms().Match(acl.PermissionScopeUpload, newpath, user) {
 		return acl.ErrPermissionDenied
 	}
 
 	// check for private
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, oldpath, user); found && !match {
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, oldpath, user); found && !match {
 		return os.ErrNotExist
 	}
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, newpath, user); found && !match {
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, newpath, user); found && !match {
 		return os.ErrNotExist
 	}
 
-	if fs.hideRE != nil {
-		if fs.hideRE.MatchString(oldpath) || fs.hideRE.MatchString(newpath) {
-			// do not leak any information, just pretend
-			// it doesnt exist
-			return os.ErrNotExist
-		}
+	if fs.hidden(oldpath) || fs.hidden(newpath) {
+		// do not leak any information, just pretend
+		// it doesnt exist
+		return os.ErrNotExist
 	}
 
-	if !fs.permissions.Match(acl.PermissionScopeRename, oldpath, user) {
+	if !fs.perms().Match(acl.PermissionScopeRename, oldpath, user) {
 
 		// not allowed to globally rename, check if this is ours and we can rename our own
-		if !fs.permissions.Match(acl.PermissionScopeRenameOwn, oldpath, user) {
+		if !fs.perms().Match(acl.PermissionScopeRenameOwn, oldpath, user) {
 			return acl.ErrPermissionDenied
 		}
 
@@ -428,10 +640,10 @@ func (fs *Filesystem) RenameFile(oldpath, newpath string, user *acl.User) error
 // DeleteFile checks to see if the user has permission to delete the file (checking delete and
 // deleteown scopes).
 func (fs *Filesystem) DeleteFile(path string, user *acl.User) error {
-	if !fs.permissions.Match(acl.PermissionScopeDelete, path, user) {
+	if !fs.perms().Match(acl.PermissionScopeDelete, path, user) {
 
 		// not allowed to globally delete, check if this is ours and we can delete our own
-		if !fs.permissions.Match(acl.PermissionScopeDeleteOwn, path, user) {
+		if !fs.perms().Match(acl.PermissionScopeDeleteOwn, path, user) {
 			return acl.ErrPermissionDenied
 		}
 
@@ -446,16 +658,14 @@ func (fs *Filesystem) DeleteFile(path string, user *acl.User) error {
 	}
 
 	// check for private
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
 		return os.ErrNotExist
 	}
 
-	if fs.hideRE != nil {
-		if fs.hideRE.MatchString(path) {
-			// do not leak any information, just pretend
-			// it doesnt exist
-			return os.ErrNotExist
-		}
+	if fs.hidden(path) {
+		// do not leak any information, just pretend
+		// it doesnt exist
+		return os.ErrNotExist
 	}
 
 	finfo, err := fs.chroot.Stat(path)
@@ -481,10 +691,10 @@ func (fs *Filesystem) DeleteFile(path string, user *acl.User) error {
 // DeleteDir checks to see if the user has permission to delete the dir (checking delete and
 // deleteown scopes).
 func (fs *Filesystem) DeleteDir(path string, user *acl.User) error {
-	if !fs.permissions.Match(acl.PermissionScopeDelete, path, user) {
+	if !fs.perms().Match(acl.PermissionScopeDelete, path, user) {
 
 		// not allowed to globally delete, check if this is ours and we can delete our own
-		if !fs.permissions.Match(acl.PermissionScopeDeleteOwn, path, user) {
+		if !fs.perms().Match(acl.PermissionScopeDeleteOwn, path, user) {
 			return acl.ErrPermissionDenied
 		}
 
@@ -499,7 +709,7 @@ func (fs *Filesystem) DeleteDir(path string, user *acl.User) error {
 	}
 
 	// check for private
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
 		return os.ErrNotExist
 	}
 
@@ -523,24 +733,96 @@ func (fs *Filesystem) DeleteDir(path string, user *acl.User) error {
 	return nil
 }
 
+// SetModTime checks to see if the user has permission to change the modification time of
+// the file (checking modtime and modtimeown scopes).
+func (fs *Filesystem) SetModTime(path string, mtime time.Time, user *acl.User) error {
+	if !fs.perms().Match(acl.PermissionScopeModTime, path, user) {
+
+		// not allowed to globally change, check if this is ours and we can change our own
+		if !fs.perms().Match(acl.PermissionScopeModTimeOwn, path, user) {
+			return acl.ErrPermissionDenied
+		}
+
+		owner, err := fs.checkOwnership(path, user)
+		if err != nil {
+			return err
+		}
+
+		if !owner {
+			return acl.ErrPermissionDenied
+		}
+	}
+
+	// check for private
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+		return os.ErrNotExist
+	}
+
+	if fs.hidden(path) {
+		// do not leak any information, just pretend
+		// it doesnt exist
+		return os.ErrNotExist
+	}
+
+	if _, err := fs.chroot.Stat(path); err != nil {
+		return err
+	}
+
+	return fs.chtimes(path, mtime)
+}
+
+// chtimes sets the access and modification times on the underlying filesystem. The
+// chroot helper that osfs uses does not implement billy.Change so we fall back to
+// the os package using the chroot's root.
+func (fs *Filesystem) chtimes(path string, mtime time.Time) error {
+	if c, ok := fs.chroot.(billy.Change); ok {
+		return c.Chtimes(path, mtime, mtime)
+	}
+
+	if realpath, ok := fs.osPath(path); ok {
+		return os.Chtimes(realpath, mtime, mtime)
+	}
+
+	return errors.New("filesystem does not support changing times")
+}
+
+// osPath returns where the path is on disk when the chroot is osfs, false
+// for any other filesystem
+func (fs *Filesystem) osPath(path string) (string, bool) {
+	c, ok := fs.chroot.(*chroot.ChrootHelper)
+	if !ok {
+		return "", false
+	}
+
+	underlying := c.Underlying()
+	if p, ok := underlying.(*polyfill.Polyfill); ok {
+		underlying = p.Basic
+	}
+
+	if _, ok := underlying.(*osfs.OS); !ok {
+		return "", false
+	}
+
+	// clean against / first so we can never escape the root
+	return filepath.Join(c.Root(), filepath.Clean("/"+path)), true
+}
+
 // ListDir checks to see if the user has permission to list the dir and then does so.
 // Has optimisation potential by being provided a FileList
 func (fs *Filesystem) ListDir(path string, user *acl.User) (FileList, error) {
-	if !fs.permissions.Match(acl.PermissionScopeDownload, path, user) {
+	if !fs.perms().Match(acl.PermissionScopeDownload, path, user) {
 		return nil, acl.ErrPermissionDenied
 	}
 
 	// check for private
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
 		return nil, os.ErrNotExist
 	}
 
-	if fs.hideRE != nil {
-		if fs.hideRE.MatchString(path) {
-			// do not leak any information, just pretend
-			// it doesnt exist
-			return nil, os.ErrNotExist
-		}
+	if fs.hidden(path) {
+		// do not leak any information, just pretend
+		// it doesnt exist
+		return nil, os.ErrNotExist
 	}
 
 	files, err := fs.chroot.ReadDir(path)
@@ -550,48 +832,167 @@ func (fs *Filesystem) ListDir(path string, user *acl.User) (FileList, error) {
 
 	var results FileList
 
-	var username, group string
-
 	for _, f := range files {
 		fullpath := filepath.Join(path, f.Name())
 
-		if fs.hideRE != nil {
-			if fs.hideRE.MatchString(fullpath) {
-				continue
-			}
+		if fs.hidden(fullpath) {
+			continue
 		}
 
 		// check for private
-		if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, fullpath, user); found && !match {
+		if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, fullpath, user); found && !match {
 			continue
 		}
 
-		// TODO do we want to use a pool here for entrys
-		entry, err := fs.shadow.Get(fullpath)
-		if err != nil {
-			username = fs.DefaultUser
-			group = fs.DefaultGroup
-		} else {
-			username = entry.User
-			group = entry.Group
+		results = append(results, fs.newFileInfo(fullpath, f, user))
+	}
+
+	return results, nil
+}
+
+// Stat checks to see if the user has permission to see the path and then returns a FileInfo
+// for it with the same owner and group rules as ListDir
+func (fs *Filesystem) Stat(path string, user *acl.User) (FileInfo, error) {
+	if !fs.perms().Match(acl.PermissionScopeDownload, path, user) {
+		return FileInfo{}, acl.ErrPermissionDenied
+	}
+
+	// check for private
+	if match, found := fs.perms().MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+		return FileInfo{}, os.ErrNotExist
+	}
+
+	if fs.hidden(path) {
+		// do not leak any information, just pretend
+		// it doesnt exist
+		return FileInfo{}, os.ErrNotExist
+	}
+
+	finfo, err := fs.chroot.Stat(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	return fs.newFileInfo(path, finfo, user), nil
+}
+
+// newFileInfo looks up the owner and group of a path in the shadow, falling back to the
+// defaults if they are not found or the user does not have permission to see them
+func (fs *Filesystem) newFileInfo(path string, finfo os.FileInfo, user *acl.User) FileInfo {
+	var username, group string
+
+	// TODO do we want to use a pool here for entrys
+	entry, err := fs.shadow.Get(path)
+	if err != nil {
+		username = fs.DefaultUser
+		group = fs.DefaultGroup
+	} else {
+		username = entry.User
+		group = entry.Group
+	}
+
+	// check if we have permission to see user and group
+	if !fs.perms().Match(acl.PermissionScopeShowUser, path, user) {
+		username = fs.DefaultUser
+	}
+	if !fs.perms().Match(acl.PermissionScopeShowGroup, path, user) {
+		group = fs.DefaultGroup
+	}
+
+	return FileInfo{
+		FileInfo: finfo,
+		Owner:    username,
+		Group:    group,
+	}
+}
+
+// CheckFXP checks to see if the user can transfer the file with a data connection to a host
+// other than the one they are connected from, upload being the direction of the transfer
+func (fs *Filesystem) CheckFXP(path string, upload bool, user *acl.User) error {
+	var scope acl.PermissionScope = acl.PermissionScopeFXPDownload
+	if upload {
+		scope = acl.PermissionScopeFXPUpload
+	}
+
+	if !fs.perms().Match(scope, path, user) {
+		return acl.ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// PermissionFacts returns the RFC 3659 perm fact for a path, describing which actions the
+// user would be allowed to perform on it. Directories are checked as if a child is being
+// created for the c and m facts, as that is how UploadFile and MakeDir will see it.
+func (fs *Filesystem) PermissionFacts(path string, isDir bool, user *acl.User) string {
+	var owner, checked bool
+
+	// only hit the shadow if an own scope needs it
+	isOwner := func() bool {
+		if !checked {
+			owner, _ = fs.checkOwnership(path, user)
+			checked = true
 		}
+		return owner
+	}
 
-		// check if we have permission to see user and group
-		if !fs.permissions.Match(acl.PermissionScopeShowUser, fullpath, user) {
-			username = fs.DefaultUser
+	match := func(scope, ownScope acl.PermissionScope) bool {
+		if fs.perms().Match(scope, path, user) {
+			return true
 		}
-		if !fs.permissions.Match(acl.PermissionScopeShowGroup, fullpath, user) {
-			group = fs.DefaultGroup
+		return fs.perms().Match(ownScope, path, user) && isOwner()
+	}
+
+	canDelete := match(acl.PermissionScopeDelete, acl.PermissionScopeDeleteOwn)
+	canRename := match(acl.PermissionScopeRename, acl.PermissionScopeRenameOwn)
+	canDownload := fs.perms().Match(acl.PermissionScopeDownload, path, user)
+
+	var b strings.Builder
+
+	if isDir {
+		child := strings.TrimSuffix(path, "/") + "/"
+
+		if fs.perms().Match(acl.PermissionScopeUpload, child, user) {
+			b.WriteString("c")
+		}
+		if canDelete {
+			b.WriteString("d")
+		}
+		if canDownload {
+			b.WriteString("el")
+		}
+		if canRename {
+			b.WriteString("f")
+		}
+		if fs.perms().Match(acl.PermissionScopeMakeDir, child, user) {
+			b.WriteString("m")
+		}
+		if canDelete {
+			b.WriteString("p")
 		}
 
-		results = append(results, FileInfo{
-			FileInfo: f,
-			Owner:    username,
-			Group:    group,
-		})
+		return b.String()
 	}
 
-	return results, nil
+	canUpload := fs.perms().Match(acl.PermissionScopeUpload, path, user)
+
+	if canUpload && match(acl.PermissionScopeResume, acl.PermissionScopeResumeOwn) {
+		b.WriteString("a")
+	}
+	if canDelete {
+		b.WriteString("d")
+	}
+	if canRename {
+		b.WriteString("f")
+	}
+	if canDownload {
+		b.WriteString("r")
+	}
+	if canUpload && canDelete {
+		b.WriteString("w")
+	}
+
+	return b.String()
 }
 
 // checkOwnership checks to see if a user is an owner of a given path. Returns bool
@@ -3,6 +3,7 @@ package vfs
 import (
 	"fmt"
 	"io/ioutil"
+	"regexp"
 	"testing"
 	"time"
 
@@ -290,7 +291,7 @@ func TestResumeUploadFile(t *testing.T) {
 					setShadowOwner(t, fs, tt.path, tt.owner)
 				}
 
-				writer, err := fs.ResumeUploadFile(tt.path, tt.user)
+				writer, err := fs.ResumeUploadFile(tt.path, int64(len(tt.content)), tt.user)
 				checkErr(t, err, tt.err)
 
 				if tt.err == nil {
@@ -684,3 +685,501 @@ func TestListDirNoPermission(t *testing.T) {
 		t.Fatal("expected files to be nil")
 	}
 }
+
+func TestStat(t *testing.T) {
+	var tests = []struct {
+		path  string
+		rules []string
+		owner string
+		group string
+		err   error
+	}{
+		{
+			"/file",
+			[]string{
+				"download /** *",
+				"showuser /** *",
+				"showgroup /** *",
+			},
+			"user",
+			"group",
+			nil,
+		},
+		{
+			"/file",
+			[]string{
+				"download /** *",
+			},
+			"nobody",
+			"nogroup",
+			nil,
+		},
+		{
+			"/file",
+			[]string{
+				"download /** !*",
+			},
+			"",
+			"",
+			acl.ErrPermissionDenied,
+		},
+		{
+			"/file",
+			[]string{
+				"download /** *",
+				"private /file !*",
+			},
+			"",
+			"",
+			errors.New("file does not exist"),
+		},
+		{
+			"/missing",
+			[]string{
+				"download /** *",
+			},
+			"",
+			"",
+			errors.New("file does not exist"),
+		},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newMemoryFilesystem(t, tt.rules)
+				if fs == nil {
+					t.Fatal("unexpected nil for fs")
+				}
+				defer stopMemoryFilesystem(t, fs)
+
+				user := newTestUser("user", "group")
+
+				createFile(t, fs, "/file", "HELLO")
+				setShadowOwner(t, fs, "/file", user)
+
+				finfo, err := fs.Stat(tt.path, user)
+				checkErr(t, err, tt.err)
+
+				if tt.err == nil {
+					if finfo.Owner != tt.owner {
+						t.Errorf("expected owner to be '%s' got: '%s'", tt.owner, finfo.Owner)
+					}
+
+					if finfo.Group != tt.group {
+						t.Errorf("expected group to be '%s' got: '%s'", tt.group, finfo.Group)
+					}
+
+					if finfo.Size() != 5 {
+						t.Errorf("expected size to be 5 got: %d", finfo.Size())
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestPermissionFacts(t *testing.T) {
+	var tests = []struct {
+		path     string
+		isDir    bool
+		rules    []string
+		owner    *acl.User
+		expected string
+	}{
+		{
+			"/file",
+			false,
+			[]string{
+				"download /** *",
+				"upload /** *",
+				"resume /** *",
+				"delete /** *",
+				"rename /** *",
+			},
+			newTestUser("owner", "group"),
+			"adfrw",
+		},
+		{
+			"/file",
+			false,
+			[]string{
+				"download /** *",
+			},
+			newTestUser("owner", "group"),
+			"r",
+		},
+		{
+			"/file",
+			false,
+			[]string{
+				"download /** *",
+				"upload /** *",
+				"resumeown /** *",
+				"deleteown /** *",
+				"renameown /** *",
+			},
+			newTestUser("user", "group"),
+			"adfrw",
+		},
+		{
+			"/file",
+			false,
+			[]string{
+				"download /** *",
+				"upload /** *",
+				"resumeown /** *",
+				"deleteown /** *",
+				"renameown /** *",
+			},
+			newTestUser("owner", "group"),
+			"r",
+		},
+		{
+			"/dir",
+			true,
+			[]string{
+				"download /** *",
+				"upload /** *",
+				"makedir /** *",
+				"delete /** *",
+				"rename /** *",
+			},
+			newTestUser("owner", "group"),
+			"cdelfmp",
+		},
+		{
+			"/dir",
+			true,
+			[]string{
+				"download /** *",
+				"upload /dir/** *",
+			},
+			newTestUser("owner", "group"),
+			"cel",
+		},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newMemoryFilesystem(t, tt.rules)
+				if fs == nil {
+					t.Fatal("unexpected nil for fs")
+				}
+				defer stopMemoryFilesystem(t, fs)
+
+				user := newTestUser("user", "group")
+
+				setShadowOwner(t, fs, tt.path, tt.owner)
+
+				got := fs.PermissionFacts(tt.path, tt.isDir, user)
+				if got != tt.expected {
+					t.Errorf("expected perm to be '%s' got: '%s'", tt.expected, got)
+				}
+			},
+		)
+	}
+}
+
+func TestSetModTime(t *testing.T) {
+	var tests = []struct {
+		create bool
+		rules  []string
+		owner  *acl.User
+		user   *acl.User
+		err    error
+	}{
+		{
+			true,
+			[]string{
+				"modtime /** *",
+			},
+			newTestUser("owner", "group"),
+			newTestUser("user", "group"),
+			nil,
+		},
+		{
+			true,
+			[]string{
+				"modtime /** !*",
+				"modtimeown /** *",
+			},
+			newTestUser("user", "group"),
+			newTestUser("user", "group"),
+			nil,
+		},
+		{
+			true,
+			[]string{
+				"modtime /** !*",
+				"modtimeown /** *",
+			},
+			newTestUser("owner", "group"),
+			newTestUser("user", "group"),
+			acl.ErrPermissionDenied,
+		},
+		{
+			true,
+			[]string{
+				"modtime /** *",
+				"private /** !*",
+			},
+			newTestUser("owner", "group"),
+			newTestUser("user", "group"),
+			errors.New("file does not exist"),
+		},
+		{
+			false,
+			[]string{
+				"modtime /** *",
+			},
+			newTestUser("owner", "group"),
+			newTestUser("user", "group"),
+			errors.New("file does not exist"),
+		},
+	}
+
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newOSFilesystem(t, tt.rules)
+				if fs == nil {
+					t.Fatal("unexpected nil for fs")
+				}
+				defer stopMemoryFilesystem(t, fs)
+
+				if tt.create {
+					createFile(t, fs, "/file", "HELLO")
+					setShadowOwner(t, fs, "/file", tt.owner)
+				}
+
+				err := fs.SetModTime("/file", mtime, tt.user)
+				checkErr(t, err, tt.err)
+
+				if tt.err == nil {
+					finfo, err := fs.chroot.Stat("/file")
+					if err != nil {
+						t.Fatalf("unexpected err in chroot.Stat: %s", err)
+					}
+
+					if !finfo.ModTime().Equal(mtime) {
+						t.Errorf("expected mod time to be '%s' got: '%s'", mtime, finfo.ModTime())
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestCheckFXP(t *testing.T) {
+	var tests = []struct {
+		path   string
+		upload bool
+		rules  []string
+		user   *acl.User
+		err    error
+	}{
+		{"/race/file", true, []string{"fxpupload /race/** *"}, newTestUser("user", "group"), nil},
+		{"/race/file", false, []string{"fxpupload /race/** *"}, newTestUser("user", "group"), acl.ErrPermissionDenied},
+		{"/race/file", false, []string{"fxpdownload /race/** *"}, newTestUser("user", "group"), nil},
+		{"/archive/file", false, []string{"fxpdownload /race/** *"}, newTestUser("user", "group"), acl.ErrPermissionDenied},
+		{"/race/file", true, []string{"fxpupload /race/** !=group *"}, newTestUser("user", "group"), acl.ErrPermissionDenied},
+		{"/race/file", true, []string{}, newTestUser("user", "group"), acl.ErrPermissionDenied},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newMemoryFilesystem(t, tt.rules)
+				if fs == nil {
+					t.Fatal("unexpected nil for fs")
+				}
+				defer stopMemoryFilesystem(t, fs)
+
+				checkErr(t, fs.CheckFXP(tt.path, tt.upload, tt.user), tt.err)
+			},
+		)
+	}
+}
+
+func TestCheckDownload(t *testing.T) {
+	var tests = []struct {
+		path  string
+		rules []string
+		user  *acl.User
+		err   error
+	}{
+		{"/file", []string{"download /** *"}, newTestUser("user", "group"), nil},
+		{"/file2", []string{"download /** *"}, newTestUser("user", "group"), errors.New("file does not exist")},
+		{"/dir", []string{"download /** *"}, newTestUser("user", "group"), errors.New("not a file")},
+		{"/file", []string{"download /** !-user *"}, newTestUser("user", "group"), acl.ErrPermissionDenied},
+		{"/file", []string{"download /** *", "private /file !*"}, newTestUser("user", "group"), errors.New("file does not exist")},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newMemoryFilesystem(t, tt.rules)
+				if fs == nil {
+					t.Fatal("unexpected nil for fs")
+				}
+				defer stopMemoryFilesystem(t, fs)
+
+				createFile(t, fs, "/file", "HELLO")
+
+				if err := fs.chroot.MkdirAll("/dir", 0777); err != nil {
+					t.Fatalf("unexpected err creating dir: %s", err)
+				}
+
+				checkErr(t, fs.CheckDownload(tt.path, tt.user), tt.err)
+			},
+		)
+	}
+}
+
+func TestCheckUpload(t *testing.T) {
+	var tests = []struct {
+		path  string
+		rules []string
+		user  *acl.User
+		err   error
+	}{
+		{"/new", []string{"upload /** *"}, newTestUser("user", "group"), nil},
+		{"/new", []string{"upload /** !-user *"}, newTestUser("user", "group"), acl.ErrPermissionDenied},
+		{"/file", []string{"upload /** *"}, newTestUser("user", "group"), acl.ErrPermissionDenied},
+		{"/file", []string{"upload /** *", "delete /** *"}, newTestUser("user", "group"), nil},
+		{"/file", []string{"upload /** *", "deleteown /** *"}, newTestUser("owner", "group"), nil},
+		{"/file", []string{"upload /** *", "deleteown /** *"}, newTestUser("user", "group"), acl.ErrPermissionDenied},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newMemoryFilesystem(t, tt.rules)
+				if fs == nil {
+					t.Fatal("unexpected nil for fs")
+				}
+				defer stopMemoryFilesystem(t, fs)
+
+				createFile(t, fs, "/file", "HELLO")
+				setShadowOwner(t, fs, "/file", newTestUser("owner", "group"))
+
+				checkErr(t, fs.CheckUpload(tt.path, tt.user), tt.err)
+
+				// nothing should have been created
+				if tt.path == "/new" {
+					if _, err := fs.chroot.Stat(tt.path); err == nil {
+						t.Errorf("expected %s not to exist", tt.path)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestReload(t *testing.T) {
+	var tests = []struct {
+		rules []string
+		hide  string
+		err   error
+	}{
+		{[]string{"download /** *"}, "", nil},
+		{[]string{"download /** !*"}, "", acl.ErrPermissionDenied},
+		{[]string{"download /** *"}, `\.nfo$`, errors.New("file does not exist")},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := newMemoryFilesystem(t, []string{"download /** !*"})
+				if fs == nil {
+					t.Fatal("unexpected nil for fs")
+				}
+				defer stopMemoryFilesystem(t, fs)
+
+				createFile(t, fs, "/file.nfo", "HELLO")
+
+				user := newTestUser("user", "group")
+
+				checkErr(t, fs.CheckDownload("/file.nfo", user), acl.ErrPermissionDenied)
+
+				var rules []acl.Rule
+				for _, l := range tt.rules {
+					r, err := acl.NewRule(l)
+					if err != nil {
+						t.Fatalf("unexpected error creating NewRules: %s", err)
+					}
+					rules = append(rules, r)
+				}
+
+				var opts FilesystemOpts
+				if len(tt.hide) > 0 {
+					opts.SetHideRE(regexp.MustCompile(tt.hide))
+				}
+
+				fs.Reload(&opts, acl.NewPermissions(rules))
+
+				checkErr(t, fs.CheckDownload("/file.nfo", user), tt.err)
+			},
+		)
+	}
+}
+
+func TestOSFile(t *testing.T) {
+	var tests = []struct {
+		fs func(*testing.T, []string) *Filesystem
+		ok bool
+	}{
+		{newOSFilesystem, true},
+		{newMemoryFilesystem, false},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				fs := tt.fs(t, []string{"download /** *"})
+				if fs == nil {
+					t.Fatal("unexpected nil for fs")
+				}
+				defer stopMemoryFilesystem(t, fs)
+
+				createFile(t, fs, "/file", "HELLO")
+
+				reader, _, err := fs.DownloadFile("/file", newTestUser("user", "group"))
+				if err != nil {
+					t.Fatalf("unexpected err downloading file: %s", err)
+				}
+				defer reader.Close()
+
+				f, ok := OSFile(reader)
+				if ok != tt.ok {
+					t.Fatalf("expected ok to be %t got %t", tt.ok, ok)
+				}
+
+				if !ok {
+					return
+				}
+
+				b, err := ioutil.ReadAll(f)
+				if err != nil {
+					t.Fatalf("unexpected err reading file: %s", err)
+				}
+
+				if string(b) != "HELLO" {
+					t.Fatalf("expected 'HELLO' got '%s'", b)
+				}
+			},
+		)
+	}
+}
@@ -10,16 +10,17 @@ import (
 // closing the writer. Very easy to make this context aware
 type writeCloser struct {
 	w              io.WriteCloser
-	h              hash.Hash32
+	hashes         []hash.Hash
 	err            error
+	closed         bool
 	onCloseSuccess func(*writeCloser) error
 }
 
-// create a new writeCloser
-func newWriteCloser(h hash.Hash32, w io.WriteCloser, onCloseSuccess func(w *writeCloser) error) *writeCloser {
+// create a new writeCloser, anything written is also written to the hashes
+func newWriteCloser(w io.WriteCloser, onCloseSuccess func(w *writeCloser) error, hashes ...hash.Hash) *writeCloser {
 	return &writeCloser{
 		w:              w,
-		h:              h,
+		hashes:         hashes,
 		err:            nil,
 		onCloseSuccess: onCloseSuccess,
 	}
@@ -33,18 +34,24 @@ func (w *writeCloser) Write(p []byte) (int, error) {
 		return n, err
 	}
 
-	_, err = w.h.Write(p)
-	if err != nil {
-		w.err = err
-		return n, err
+	for _, h := range w.hashes {
+		if _, err := h.Write(p[:n]); err != nil {
+			w.err = err
+			return n, err
+		}
 	}
 
 	return n, nil
 }
 
 // Close closes the underlying io.WriteCloser and if no errors were
-// made, it calls the onSuccess callback
+// made, it calls the onSuccess callback. Safe to call more than once
 func (w *writeCloser) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
 	if err := w.w.Close(); err != nil {
 		return err
 	}